@@ -1,19 +1,49 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/compiler"
+	"github.com/astraikis/harp/internal/diagnostics"
 	"github.com/astraikis/harp/internal/interpreter"
+	"github.com/astraikis/harp/internal/models"
 	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/resolver"
 	"github.com/astraikis/harp/internal/scanner"
+	"github.com/astraikis/harp/internal/vm"
 )
 
+// useVM is whether scripts run on the bytecode
+// VM instead of the tree-walking interpreter.
+var useVM = flag.Bool("vm", false, "run the script on the bytecode VM")
+
+// maxDepth is how deeply calls to Harp functions can nest.
+var maxDepth = flag.Int("max-depth", models.DefaultMaxDepth, "how deeply function calls can nest")
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: harp <script>")
+	flag.Usage = func() {
+		fmt.Println("Usage: harp [--vm] [--max-depth=N] [script]")
+	}
+	flag.Parse()
+
+	if *maxDepth < 1 {
+		fmt.Println("Error: --max-depth must be at least 1.")
+		os.Exit(1)
+	}
+
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(1)
+	} else if flag.NArg() == 1 {
+		runFile(flag.Arg(0))
+	} else if *useVM {
+		fmt.Println("Error: --vm only runs scripts. The REPL always uses the interpreter.")
+		os.Exit(1)
 	} else {
-		runFile(os.Args[1])
+		runPrompt()
 	}
 }
 
@@ -24,18 +54,61 @@ func runFile(path string) {
 		os.Exit(1)
 	}
 
-	run(string(file))
+	run(path, string(file))
 }
 
-func run(source string) {
-	tokens := scanner.Scan(source)
+// run runs source, which was read from path. Each stage
+// only runs if the ones before it found no errors, and
+// warnings are shown before the program starts.
+func run(path string, source string) {
+	renderer := diagnostics.NewRenderer(os.Stdout, path, source)
+	var found diagnostics.List
+
+	tokens, scanErrors := scanner.Scan(source)
+	found.Add(scanErrors...)
+	exitOnErrors(renderer, &found)
 
 	stmts, parseErrors := parser.Parse(tokens)
-	if parseErrors != nil {
-		for _, parseError := range parseErrors {
-			fmt.Println(parseError.Error())
-		}
+	found.Add(parseErrors...)
+	exitOnErrors(renderer, &found)
+
+	found.Add(checker.Check(stmts)...)
+	exitOnErrors(renderer, &found)
+
+	found.Add(resolver.Resolve(stmts)...)
+	exitOnErrors(renderer, &found)
+
+	var function *compiler.Function
+	if *useVM {
+		var compileErrors []error
+		function, compileErrors = compiler.Compile(stmts)
+		found.Add(compileErrors...)
+		exitOnErrors(renderer, &found)
 	}
 
-	interpreter.Interpret(stmts)
+	renderer.RenderAll(&found)
+
+	var err error
+	if *useVM {
+		machine := vm.NewVM()
+		machine.SetMaxDepth(*maxDepth)
+		err = machine.Run(function)
+	} else {
+		runner := interpreter.NewInterpreter()
+		runner.SetMaxDepth(*maxDepth)
+		err = runner.Interpret(stmts)
+	}
+	if err != nil {
+		renderer.Render(diagnostics.From(err))
+		os.Exit(1)
+	}
+}
+
+// exitOnErrors renders found and exits
+// if it holds anything but warnings.
+func exitOnErrors(renderer *diagnostics.Renderer, found *diagnostics.List) {
+	if found.HasErrors() {
+		renderer.RenderAll(found)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/interpreter"
+	"github.com/astraikis/harp/internal/models"
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/resolver"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+const replHelp = `Enter Harp statements or expressions. Commands:
+  :tokens <code>  print the tokens scanned from code
+  :ast <code>     print the syntax tree parsed from code
+  :env            print global variables
+  :history        print previous inputs
+  :help           print this message
+  :quit           exit the REPL`
+
+// repl holds the state kept between
+// entries typed into the REPL.
+type repl struct {
+	checker     *checker.Checker
+	interpreter *interpreter.Interpreter
+	history     []string
+}
+
+// runPrompt reads and runs input a line at a time
+// until stdin is closed or :quit is entered.
+func runPrompt() {
+	input := bufio.NewScanner(os.Stdin)
+	r := &repl{
+		checker:     checker.NewChecker(),
+		interpreter: interpreter.NewInterpreter(),
+		history:     loadHistory(),
+	}
+	r.interpreter.SetMaxDepth(*maxDepth)
+
+	fmt.Println("Harp REPL. Type :help for commands.")
+	for {
+		source, ok := readInput(input)
+		if !ok {
+			fmt.Println()
+			return
+		}
+
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		r.history = append(r.history, source)
+		saveHistory(source)
+
+		if strings.HasPrefix(source, ":") {
+			if !r.runCommand(source) {
+				return
+			}
+			continue
+		}
+
+		r.runLine(source)
+	}
+}
+
+// readInput reads lines until every bracket opened
+// in them is closed and returns them joined.
+func readInput(input *bufio.Scanner) (string, bool) {
+	var lines []string
+
+	fmt.Print("> ")
+	for {
+		if !input.Scan() {
+			return "", false
+		}
+		lines = append(lines, input.Text())
+
+		source := strings.Join(lines, "\n")
+		if unclosedBrackets(source) <= 0 {
+			return source, true
+		}
+		fmt.Print("... ")
+	}
+}
+
+// unclosedBrackets returns the number of brackets opened
+// in source that haven't been closed. Source that doesn't
+// scan, such as an unterminated string, counts as closed
+// so that the error is shown rather than waited on.
+func unclosedBrackets(source string) int {
+	tokens, scanErrors := scanner.Scan(source)
+	if scanErrors != nil {
+		return 0
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case models.LeftBrace, models.LeftParen, models.LEFT_SQUARE:
+			depth++
+		case models.RightBrace, models.RightParen, models.RIGHT_SQUARE:
+			depth--
+		}
+	}
+	return depth
+}
+
+// runCommand runs a meta-command and reports
+// whether the REPL should keep going.
+func (r *repl) runCommand(source string) bool {
+	command, argument, _ := strings.Cut(source, " ")
+
+	switch command {
+	case ":tokens":
+		tokens, scanErrors := scanner.Scan(argument)
+		printErrors(scanErrors)
+		scanner.PrintTokens(tokens)
+	case ":ast":
+		stmts, parseErrors := parseInput(argument)
+		printErrors(parseErrors)
+		parser.PrintStatements(stmts)
+	case ":env":
+		r.interpreter.PrintGlobals()
+	case ":history":
+		for i, entry := range r.history {
+			fmt.Printf("%4d  %s\n", i+1, entry)
+		}
+	case ":help":
+		fmt.Println(replHelp)
+	case ":quit", ":q":
+		return false
+	default:
+		fmt.Printf("Unknown command '%s'. Type :help for commands.\n", command)
+	}
+
+	return true
+}
+
+// runLine runs one entry typed into the REPL. If it ends
+// with an expression statement that isn't an assignment,
+// the value is printed.
+func (r *repl) runLine(source string) {
+	renderer := diagnostics.NewRenderer(os.Stdout, "<repl>", source)
+	var found diagnostics.List
+
+	stmts, parseErrors := parseInput(source)
+	found.Add(parseErrors...)
+	if !found.HasErrors() {
+		found.Add(r.checker.Check(stmts)...)
+	}
+	if !found.HasErrors() {
+		found.Add(resolver.Resolve(stmts)...)
+	}
+	renderer.RenderAll(&found)
+	if found.HasErrors() {
+		return
+	}
+
+	stmts, last := splitEcho(stmts)
+	if err := r.interpreter.Interpret(stmts); err != nil {
+		renderer.Render(diagnostics.From(err))
+		return
+	}
+
+	if last != nil {
+		value, err := r.interpreter.Evaluate(last)
+		if err != nil {
+			renderer.Render(diagnostics.From(err))
+			return
+		}
+		if value != nil {
+			fmt.Println(models.Format(value))
+		}
+	}
+}
+
+// splitEcho splits the last statement off stmts if its value
+// should be printed, which it is for an expression statement
+// unless it assigns a variable, list element or field.
+func splitEcho(stmts []models.Stmt) ([]models.Stmt, models.Expr) {
+	if len(stmts) == 0 {
+		return stmts, nil
+	}
+	exprStmt, ok := stmts[len(stmts)-1].(models.ExprStmt)
+	if !ok {
+		return stmts, nil
+	}
+
+	switch exprStmt.Expression.(type) {
+	case models.AssignExpr, models.IndexSetExpr, models.SetExpr:
+		return stmts, nil
+	}
+	return stmts[:len(stmts)-1], exprStmt.Expression
+}
+
+// parseInput parses source, retrying with a semicolon added
+// so bare expressions like 1 + 2 can be entered.
+func parseInput(source string) ([]models.Stmt, []error) {
+	tokens, scanErrors := scanner.Scan(source)
+	if scanErrors != nil {
+		return nil, scanErrors
+	}
+
+	stmts, parseErrors := parser.Parse(tokens)
+	if parseErrors == nil || strings.HasSuffix(source, ";") {
+		return stmts, parseErrors
+	}
+
+	tokens, _ = scanner.Scan(source + ";")
+	retried, retryErrors := parser.Parse(tokens)
+	if retryErrors != nil {
+		return stmts, parseErrors
+	}
+	return retried, nil
+}
+
+func printErrors(errs []error) {
+	for _, err := range errs {
+		fmt.Println(err.Error())
+	}
+}
+
+// historyPath returns the file REPL input is
+// saved to, or "" if there's no home directory.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".harp_history")
+}
+
+func loadHistory() []string {
+	path := historyPath()
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var history []string
+	for _, entry := range strings.Split(string(file), "\x00") {
+		if entry != "" {
+			history = append(history, entry)
+		}
+	}
+	return history
+}
+
+// saveHistory appends entry to the history file. Entries
+// are separated by null bytes since they can span lines.
+func saveHistory(entry string) {
+	path := historyPath()
+	if path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	file.WriteString(entry + "\x00")
+}
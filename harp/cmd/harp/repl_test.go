@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestUnclosedBrackets(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{`print(1);`, 0},
+		{`func f() {`, 1},
+		{"int x = (1 +\n2);", 0},
+		{`list<int> xs = [[1`, 2},
+		{`print("\"(");`, 0},
+		{`print(")"); // (`, 0},
+		{`print("(`, 0},
+	}
+
+	for _, test := range tests {
+		if got := unclosedBrackets(test.source); got != test.want {
+			t.Errorf("unclosedBrackets(%q) = %d, want %d", test.source, got, test.want)
+		}
+	}
+}
+
+func TestSplitEcho(t *testing.T) {
+	tests := []struct {
+		source string
+		echoed bool
+	}{
+		{`1 + 2`, true},
+		{`int x = 1; x`, true},
+		{`int x = 1; x = 5`, false},
+		{`int x = 1; x += 5`, false},
+		{`int x = 1; x++`, false},
+		{`list<int> xs = [1]; xs[0] = 2`, false},
+		{`list<int> xs = [1]; xs[0]`, true},
+		{`struct P { int x; } P p = P{x: 1}; p.x = 2`, false},
+		{`struct P { int x; } P p = P{x: 1}; p.x`, true},
+		{`print(1)`, true},
+		{`int y = 2;`, false},
+	}
+
+	for _, test := range tests {
+		stmts, errs := parseInput(test.source)
+		if errs != nil {
+			t.Fatalf("parseInput(%q): %v", test.source, errs)
+		}
+		rest, echoed := splitEcho(stmts)
+		if (echoed != nil) != test.echoed {
+			t.Errorf("splitEcho(%q) echoes %v, want echoed = %t", test.source, echoed, test.echoed)
+		}
+		want := len(stmts)
+		if test.echoed {
+			want--
+		}
+		if len(rest) != want {
+			t.Errorf("splitEcho(%q) left %d statements, want %d", test.source, len(rest), want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+// builtinRule checks the arguments of a call
+// to a builtin and returns its result type.
+type builtinRule func(c *Checker, expr models.CallExpr, arguments []*Type) *Type
+
+var builtins = map[string]builtinRule{
+	"clock":  (*Checker).checkClock,
+	"print":  (*Checker).checkPrint,
+	"append": (*Checker).checkAppend,
+	"pop":    (*Checker).checkPop,
+	"insert": (*Checker).checkInsert,
+	"slice":  (*Checker).checkSlice,
+	"has":    (*Checker).checkHas,
+	"delete": (*Checker).checkDelete,
+	"keys":   (*Checker).checkKeys,
+	"values": (*Checker).checkValues,
+}
+
+// builtinOverloads holds the builtins that are overloaded
+// like functions declared in Harp, in the order of their
+// runtime names in models.Builtins.
+var builtinOverloads = map[string][]*Type{
+	"len": {
+		{Kind: Func, Params: []*Type{listOf(anyType)}, Return: intType},
+		{Kind: Func, Params: []*Type{stringType}, Return: intType},
+		{Kind: Func, Params: []*Type{mapOf(anyType, anyType)}, Return: intType},
+	},
+	"range": {
+		{Kind: Func, Params: []*Type{intType, intType}, Return: rangeType},
+		{Kind: Func, Params: []*Type{intType, intType, intType}, Return: rangeType},
+	},
+}
+
+func defineBuiltins(builtinScope *Scope) {
+	for name := range builtins {
+		defineType(name, &Type{Kind: Func, Builtin: name}, builtinScope)
+	}
+	for name, overloads := range builtinOverloads {
+		for _, overload := range overloads {
+			declareFunction(name, overload, builtinScope)
+		}
+	}
+}
+
+func (c *Checker) checkClock(expr models.CallExpr, arguments []*Type) *Type {
+	c.checkArity(expr, "clock", arguments, 0)
+	return intType
+}
+
+// checkPrint allows any number of arguments of any type.
+func (c *Checker) checkPrint(expr models.CallExpr, arguments []*Type) *Type {
+	return voidType
+}
+
+func (c *Checker) checkAppend(expr models.CallExpr, arguments []*Type) *Type {
+	if c.checkArity(expr, "append", arguments, 2) {
+		elem := c.checkListArgument(expr, "append", arguments[0])
+		c.checkArgument(expr, "append", 2, elem, arguments[1])
+	}
+	return voidType
+}
+
+func (c *Checker) checkPop(expr models.CallExpr, arguments []*Type) *Type {
+	if !c.checkArity(expr, "pop", arguments, 1) {
+		return invalidType
+	}
+	return c.checkListArgument(expr, "pop", arguments[0])
+}
+
+func (c *Checker) checkInsert(expr models.CallExpr, arguments []*Type) *Type {
+	if c.checkArity(expr, "insert", arguments, 3) {
+		elem := c.checkListArgument(expr, "insert", arguments[0])
+		c.checkArgument(expr, "insert", 2, intType, arguments[1])
+		c.checkArgument(expr, "insert", 3, elem, arguments[2])
+	}
+	return voidType
+}
+
+func (c *Checker) checkSlice(expr models.CallExpr, arguments []*Type) *Type {
+	if !c.checkArity(expr, "slice", arguments, 3) {
+		return invalidType
+	}
+
+	c.checkListArgument(expr, "slice", arguments[0])
+	c.checkArgument(expr, "slice", 2, intType, arguments[1])
+	c.checkArgument(expr, "slice", 3, intType, arguments[2])
+	if arguments[0].Kind != List {
+		return invalidType
+	}
+	return arguments[0]
+}
+
+func (c *Checker) checkHas(expr models.CallExpr, arguments []*Type) *Type {
+	if c.checkArity(expr, "has", arguments, 2) {
+		key, _ := c.checkMapArgument(expr, "has", arguments[0])
+		c.checkArgument(expr, "has", 2, key, arguments[1])
+	}
+	return boolType
+}
+
+func (c *Checker) checkDelete(expr models.CallExpr, arguments []*Type) *Type {
+	if c.checkArity(expr, "delete", arguments, 2) {
+		key, _ := c.checkMapArgument(expr, "delete", arguments[0])
+		c.checkArgument(expr, "delete", 2, key, arguments[1])
+	}
+	return voidType
+}
+
+func (c *Checker) checkKeys(expr models.CallExpr, arguments []*Type) *Type {
+	if !c.checkArity(expr, "keys", arguments, 1) {
+		return invalidType
+	}
+	key, _ := c.checkMapArgument(expr, "keys", arguments[0])
+	return listOf(key)
+}
+
+func (c *Checker) checkValues(expr models.CallExpr, arguments []*Type) *Type {
+	if !c.checkArity(expr, "values", arguments, 1) {
+		return invalidType
+	}
+	_, elem := c.checkMapArgument(expr, "values", arguments[0])
+	return listOf(elem)
+}
+
+// checkArity reports an error and returns false
+// if there aren't exactly arity arguments.
+func (c *Checker) checkArity(expr models.CallExpr, name string, arguments []*Type, arity int) bool {
+	if len(arguments) != arity {
+		c.reportError(expr.Paren, fmt.Sprintf("Expected %s to '%s' but got %d.", models.Arguments(arity), name, len(arguments)))
+		return false
+	}
+	return true
+}
+
+// checkListArgument reports an error if argument isn't
+// a list and returns the list's element type.
+func (c *Checker) checkListArgument(expr models.CallExpr, name string, argument *Type) *Type {
+	if argument.Kind == Invalid {
+		return invalidType
+	}
+	if argument.Kind != List {
+		c.reportError(expr.Paren, fmt.Sprintf("Argument 1 to '%s' must be a list, got %s.", name, argument))
+		return invalidType
+	}
+	return argument.Elem
+}
+
+// checkMapArgument reports an error if argument isn't a
+// map and returns the map's key and value types.
+func (c *Checker) checkMapArgument(expr models.CallExpr, name string, argument *Type) (*Type, *Type) {
+	if argument.Kind == Invalid {
+		return invalidType, invalidType
+	}
+	if argument.Kind != Map {
+		c.reportError(expr.Paren, fmt.Sprintf("Argument 1 to '%s' must be a map, got %s.", name, argument))
+		return invalidType, invalidType
+	}
+	return argument.Key, argument.Elem
+}
+
+func (c *Checker) checkArgument(expr models.CallExpr, name string, position int, expected *Type, argument *Type) {
+	if !assignable(expected, argument) {
+		c.reportError(expr.Paren, fmt.Sprintf("Cannot use %s as argument %d to '%s' of type %s.", argument, position, name, expected))
+	}
+}
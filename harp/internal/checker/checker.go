@@ -0,0 +1,923 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+// Checker finds type errors in a syntax tree. Each
+// Checker holds its own state, so separate
+// Checkers can run at the same time.
+type Checker struct {
+	globals   *Scope
+	currScope *Scope
+	// currFunction is the type of the function whose
+	// body is being checked, or nil at the top level.
+	currFunction *Type
+	// bodies checks the bodies of the functions declared
+	// in the statements being checked. They're checked
+	// after the statements, so a function can call
+	// one declared after it.
+	bodies      []func()
+	checkErrors []error
+}
+
+// NewChecker returns a Checker whose global
+// scope holds only the builtins.
+func NewChecker() *Checker {
+	globals := &Scope{types: map[string]*Type{}, parent: nil}
+	defineBuiltins(globals)
+
+	return &Checker{globals: globals, currScope: globals}
+}
+
+// DefineGlobal declares a global of type t, for
+// values that come from outside the program.
+func (c *Checker) DefineGlobal(name string, t *Type) {
+	defineType(name, t, c.globals)
+}
+
+// Check walks a list of stmts and returns
+// every type error found in them.
+func Check(statements []models.Stmt) []error {
+	return NewChecker().Check(statements)
+}
+
+// Check walks a list of stmts and returns every type
+// error found in them. Declarations are remembered
+// between calls, so later stmts can use earlier ones.
+func (c *Checker) Check(statements []models.Stmt) []error {
+	c.checkErrors = nil
+	c.checkStmts(statements)
+	return c.checkErrors
+}
+
+// checkStmts checks statements in the current scope,
+// then the bodies of the functions they declare.
+func (c *Checker) checkStmts(statements []models.Stmt) {
+	enclosing := c.bodies
+	c.bodies = nil
+
+	// Names declared in the statements are in scope from the
+	// start, but have no type until their declaration is
+	// checked. The resolver reports using them any earlier.
+	for _, stmt := range statements {
+		if name, ok := declaredName(stmt); ok {
+			if _, declared := c.currScope.types[name]; !declared {
+				defineType(name, invalidType, c.currScope)
+			}
+		}
+	}
+
+	for _, stmt := range statements {
+		c.checkStmt(stmt)
+	}
+
+	bodies := c.bodies
+	c.bodies = enclosing
+	for _, body := range bodies {
+		body()
+	}
+}
+
+func (c *Checker) checkStmt(stmt models.Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Checker) VisitExprStmt(stmt models.ExprStmt) (interface{}, error) {
+	c.checkExpr(stmt.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitVarStmt(stmt models.VarStmt) (interface{}, error) {
+	c.checkVarStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitBlockStmt(stmt models.BlockStmt) (interface{}, error) {
+	c.checkBlockStmt(stmt.Statements, &Scope{types: map[string]*Type{}, parent: c.currScope})
+	return nil, nil
+}
+
+func (c *Checker) VisitIfStmt(stmt models.IfStmt) (interface{}, error) {
+	c.checkIfStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt models.WhileStmt) (interface{}, error) {
+	c.checkWhileStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitForEachStmt(stmt models.ForEachStmt) (interface{}, error) {
+	c.checkForEachStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitFuncStmt(stmt models.FuncStmt) (interface{}, error) {
+	c.checkFuncStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitReturnStmt(stmt models.ReturnStmt) (interface{}, error) {
+	c.checkReturnStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitBreakStmt(stmt models.BreakStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *Checker) VisitContinueStmt(stmt models.ContinueStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *Checker) VisitStructStmt(stmt models.StructStmt) (interface{}, error) {
+	c.checkStructStmt(stmt)
+	return nil, nil
+}
+
+func (c *Checker) VisitErrorStmt(stmt models.ErrorStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *Checker) checkStructStmt(stmt models.StructStmt) {
+	structType := &Type{Kind: Struct, Name: stmt.Name.Lexeme}
+	if stmt.Name.Lexeme == "range" {
+		c.reportError(stmt.Name, "Cannot name a struct 'range', which is already a type.")
+	}
+
+	// Define the struct before its fields so they can refer to it.
+	defineType(stmt.Name.Lexeme, &Type{Kind: StructDef, Elem: structType}, c.currScope)
+
+	for _, field := range stmt.Fields {
+		if structType.field(field.Name.Lexeme) != nil {
+			c.reportError(field.Name, fmt.Sprintf("Duplicate field '%s' in struct '%s'.", field.Name.Lexeme, stmt.Name.Lexeme))
+			continue
+		}
+		structType.Fields = append(structType.Fields, Field{Name: field.Name.Lexeme, Type: c.resolveType(field.Type)})
+	}
+}
+
+func (c *Checker) checkVarStmt(stmt models.VarStmt) {
+	declared := c.resolveType(stmt.Type)
+
+	if stmt.Initializer != nil {
+		value := c.checkExpr(stmt.Initializer)
+		if !assignable(declared, value) {
+			c.reportSpan(stmt.Initializer.Span(), fmt.Sprintf("Cannot assign %s to variable '%s' of type %s.", value, stmt.Name.Lexeme, declared))
+		}
+	}
+
+	defineType(stmt.Name.Lexeme, declared, c.currScope)
+}
+
+func (c *Checker) checkBlockStmt(blockStmts []models.Stmt, blockScope *Scope) {
+	prevScope := c.currScope
+	c.currScope = blockScope
+	c.checkStmts(blockStmts)
+	c.currScope = prevScope
+}
+
+func (c *Checker) checkIfStmt(stmt models.IfStmt) {
+	c.checkCondition(stmt.Condition, stmt.Keyword)
+
+	c.checkStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		c.checkStmt(stmt.ElseBranch)
+	}
+}
+
+func (c *Checker) checkWhileStmt(stmt models.WhileStmt) {
+	c.checkCondition(stmt.Condition, stmt.Keyword)
+	c.checkStmt(stmt.Body)
+	if stmt.Increment != nil {
+		c.checkExpr(stmt.Increment)
+	}
+}
+
+// checkForEachStmt checks that the loop variables have the
+// types of what the iterable gives them. With two variables,
+// the first gets the index or key and the second the value.
+func (c *Checker) checkForEachStmt(stmt models.ForEachStmt) {
+	iterable := c.checkExpr(stmt.Iterable)
+
+	var expected []*Type
+	var roles []string
+	switch iterable.Kind {
+	case Invalid:
+	case List:
+		expected, roles = []*Type{iterable.Elem}, []string{"elements"}
+		if len(stmt.Vars) == 2 {
+			expected, roles = []*Type{intType, iterable.Elem}, []string{"indexes", "elements"}
+		}
+	case Range:
+		expected, roles = []*Type{intType}, []string{"ints"}
+		if len(stmt.Vars) == 2 {
+			expected, roles = []*Type{intType, intType}, []string{"indexes", "ints"}
+		}
+	case String:
+		expected, roles = []*Type{stringType}, []string{"characters"}
+		if len(stmt.Vars) == 2 {
+			expected, roles = []*Type{intType, stringType}, []string{"indexes", "characters"}
+		}
+	case Map:
+		expected, roles = []*Type{iterable.Key}, []string{"keys"}
+		if len(stmt.Vars) == 2 {
+			expected, roles = []*Type{iterable.Key, iterable.Elem}, []string{"keys", "values"}
+		}
+	default:
+		c.reportSpan(stmt.Iterable.Span(), fmt.Sprintf("Cannot loop over value of type %s.", iterable))
+	}
+
+	loopScope := &Scope{types: map[string]*Type{}, parent: c.currScope}
+	for i, v := range stmt.Vars {
+		declared := c.resolveType(v.Type)
+		if expected != nil && !assignable(declared, expected[i]) {
+			c.reportSpan(v.Type.Span(), fmt.Sprintf("Loop variable '%s' must be %s to hold the %s of %s.", v.Name.Lexeme, expected[i], roles[i], iterable))
+		}
+		defineType(v.Name.Lexeme, declared, loopScope)
+	}
+
+	prevScope := c.currScope
+	c.currScope = loopScope
+	c.checkStmt(stmt.Body)
+	c.currScope = prevScope
+}
+
+func (c *Checker) checkFuncStmt(stmt models.FuncStmt) {
+	function := c.functionType(stmt.Params, stmt.ReturnType)
+
+	// Functions with the same name but different
+	// parameters are overloads of each other. The body
+	// is checked once the rest of the block has been,
+	// so it can call functions declared after it.
+	stmt.Binding.Overload = declareFunction(stmt.Name.Lexeme, function, c.currScope)
+
+	c.bodies = append(c.bodies, func() {
+		if !c.checkFunctionBody(function, stmt.Params, stmt.Body) {
+			c.reportError(stmt.Name, fmt.Sprintf("Function '%s' must return a value of type %s on every path.", stmt.Name.Lexeme, function.Return))
+		}
+	})
+}
+
+func (c *Checker) checkFuncExpr(expr models.FuncExpr) *Type {
+	function := c.functionType(expr.Params, expr.ReturnType)
+
+	if !c.checkFunctionBody(function, expr.Params, expr.Body) {
+		c.reportError(expr.Keyword, fmt.Sprintf("Function must return a value of type %s on every path.", function.Return))
+	}
+
+	return function
+}
+
+// functionType returns the type of a function
+// with params and returnType.
+func (c *Checker) functionType(params []models.FuncParam, returnType *models.TypeExpr) *Type {
+	function := &Type{Kind: Func, Return: voidType}
+	if returnType != nil {
+		function.Return = c.resolveType(*returnType)
+	}
+	for _, param := range params {
+		function.Params = append(function.Params, c.resolveType(param.Type))
+	}
+
+	return function
+}
+
+// checkFunctionBody checks the body of a function of type
+// function in a scope nested inside the one it's defined
+// in, so the body can use the variables around it. It
+// returns false if the function must return a value
+// but can reach the end of its body.
+func (c *Checker) checkFunctionBody(function *Type, params []models.FuncParam, body []models.Stmt) bool {
+	bodyScope := &Scope{types: map[string]*Type{}, parent: c.currScope}
+	for i, param := range params {
+		defineType(param.Name.Lexeme, function.Params[i], bodyScope)
+	}
+
+	enclosingFunction := c.currFunction
+	c.currFunction = function
+	c.checkBlockStmt(body, bodyScope)
+	c.currFunction = enclosingFunction
+
+	return function.Return.Kind == Void || alwaysReturns(body)
+}
+
+func (c *Checker) checkReturnStmt(stmt models.ReturnStmt) {
+	if c.currFunction == nil {
+		c.reportError(stmt.Keyword, "Cannot return from top-level code.")
+		if stmt.Value != nil {
+			c.checkExpr(stmt.Value)
+		}
+		return
+	}
+
+	if stmt.Value == nil {
+		if c.currFunction.Return.Kind != Void {
+			c.reportError(stmt.Keyword, fmt.Sprintf("Missing return value of type %s.", c.currFunction.Return))
+		}
+		return
+	}
+
+	value := c.checkExpr(stmt.Value)
+	if c.currFunction.Return.Kind == Void {
+		c.reportError(stmt.Keyword, "Cannot return a value from a function with no return type.")
+	} else if !assignable(c.currFunction.Return, value) {
+		c.reportSpan(stmt.Value.Span(), fmt.Sprintf("Cannot return %s from a function returning %s.", value, c.currFunction.Return))
+	}
+}
+
+// alwaysReturns reports whether running stmts
+// is guaranteed to end in a return statement.
+func alwaysReturns(stmts []models.Stmt) bool {
+	for _, stmt := range stmts {
+		if stmtAlwaysReturns(stmt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stmtAlwaysReturns(stmt models.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case models.ReturnStmt:
+		return true
+	case models.BlockStmt:
+		return alwaysReturns(stmt.Statements)
+	case models.IfStmt:
+		return stmt.ElseBranch != nil && stmtAlwaysReturns(stmt.ThenBranch) && stmtAlwaysReturns(stmt.ElseBranch)
+	case models.WhileStmt:
+		// A while (true) loop without a break can
+		// only be left by returning.
+		literal, ok := stmt.Condition.(models.LiteralExpr)
+		return ok && literal.Literal == true && !breaksOut(stmt.Body, stmt.Label, true)
+	}
+
+	return false
+}
+
+// breaksOut reports whether stmt, which is in the body of
+// the loop labeled label, has a break statement leaving that
+// loop. Innermost is whether the loop is the innermost one
+// around stmt, so that a break without a label leaves it.
+func breaksOut(stmt models.Stmt, label *models.Token, innermost bool) bool {
+	switch stmt := stmt.(type) {
+	case models.BreakStmt:
+		if stmt.Label == nil {
+			return innermost
+		}
+		return label != nil && stmt.Label.Lexeme == label.Lexeme
+	case models.BlockStmt:
+		for _, inner := range stmt.Statements {
+			if breaksOut(inner, label, innermost) {
+				return true
+			}
+		}
+	case models.IfStmt:
+		return breaksOut(stmt.ThenBranch, label, innermost) ||
+			(stmt.ElseBranch != nil && breaksOut(stmt.ElseBranch, label, innermost))
+	case models.WhileStmt:
+		return breaksOut(stmt.Body, label, false)
+	case models.ForEachStmt:
+		return breaksOut(stmt.Body, label, false)
+	}
+
+	return false
+}
+
+// checkCondition reports an error if condition
+// isn't a bool.
+func (c *Checker) checkCondition(condition models.Expr, keyword models.Token) {
+	conditionType := c.checkExpr(condition)
+	if !assignable(boolType, conditionType) {
+		c.reportSpan(condition.Span(), fmt.Sprintf("Condition of '%s' must be bool, got %s.", keyword.Lexeme, conditionType))
+	}
+}
+
+// checkExpr returns the type of expr and
+// reports any type errors inside it.
+func (c *Checker) checkExpr(expr models.Expr) *Type {
+	t, _ := expr.Accept(c)
+	return t.(*Type)
+}
+
+func (c *Checker) VisitBinaryExpr(expr models.BinaryExpr) (interface{}, error) {
+	return c.checkBinaryExpr(expr), nil
+}
+
+func (c *Checker) VisitUnaryExpr(expr models.UnaryExpr) (interface{}, error) {
+	return c.checkUnaryExpr(expr), nil
+}
+
+func (c *Checker) VisitLiteralExpr(expr models.LiteralExpr) (interface{}, error) {
+	return c.checkLiteralExpr(expr), nil
+}
+
+func (c *Checker) VisitGroupingExpr(expr models.GroupingExpr) (interface{}, error) {
+	return c.checkExpr(expr.Expression), nil
+}
+
+func (c *Checker) VisitVarExpr(expr models.VarExpr) (interface{}, error) {
+	return c.checkVarExpr(expr), nil
+}
+
+func (c *Checker) VisitAssignExpr(expr models.AssignExpr) (interface{}, error) {
+	return c.checkAssignExpr(expr), nil
+}
+
+func (c *Checker) VisitLogicExpr(expr models.LogicExpr) (interface{}, error) {
+	return c.checkLogicExpr(expr), nil
+}
+
+func (c *Checker) VisitCallExpr(expr models.CallExpr) (interface{}, error) {
+	return c.checkCallExpr(expr), nil
+}
+
+func (c *Checker) VisitListExpr(expr models.ListExpr) (interface{}, error) {
+	return c.checkListExpr(expr), nil
+}
+
+func (c *Checker) VisitMapExpr(expr models.MapExpr) (interface{}, error) {
+	return c.checkMapExpr(expr), nil
+}
+
+func (c *Checker) VisitIndexExpr(expr models.IndexExpr) (interface{}, error) {
+	return c.checkIndex(expr.Object, expr.Index), nil
+}
+
+func (c *Checker) VisitIndexSetExpr(expr models.IndexSetExpr) (interface{}, error) {
+	return c.checkIndexSetExpr(expr), nil
+}
+
+func (c *Checker) VisitStructExpr(expr models.StructExpr) (interface{}, error) {
+	return c.checkStructExpr(expr), nil
+}
+
+func (c *Checker) VisitGetExpr(expr models.GetExpr) (interface{}, error) {
+	return c.checkField(expr.Object, expr.Name), nil
+}
+
+func (c *Checker) VisitSetExpr(expr models.SetExpr) (interface{}, error) {
+	return c.checkSetExpr(expr), nil
+}
+
+func (c *Checker) VisitFuncExpr(expr models.FuncExpr) (interface{}, error) {
+	return c.checkFuncExpr(expr), nil
+}
+
+func (c *Checker) VisitErrorExpr(expr models.ErrorExpr) (interface{}, error) {
+	return invalidType, nil
+}
+
+func (c *Checker) checkStructExpr(expr models.StructExpr) *Type {
+	named := lookupType(expr.Name.Lexeme, c.currScope)
+	if named == nil || named.Kind != StructDef {
+		// A struct used before its declaration is left
+		// for the resolver to report.
+		if named == nil || named.Kind != Invalid {
+			c.reportError(expr.Name, fmt.Sprintf("Unknown struct '%s'.", expr.Name.Lexeme), didYouMean(expr.Name.Lexeme, c.currScope, true)...)
+		}
+		for _, field := range expr.Fields {
+			c.checkExpr(field.Value)
+		}
+		return invalidType
+	}
+	structType := named.Elem
+
+	initialized := map[string]bool{}
+	for _, field := range expr.Fields {
+		value := c.checkExpr(field.Value)
+
+		fieldType := structType.field(field.Name.Lexeme)
+		if fieldType == nil {
+			c.reportError(field.Name, fmt.Sprintf("Struct '%s' has no field '%s'.", structType.Name, field.Name.Lexeme))
+			continue
+		}
+		if initialized[field.Name.Lexeme] {
+			c.reportError(field.Name, fmt.Sprintf("Field '%s' is set more than once.", field.Name.Lexeme))
+		}
+		initialized[field.Name.Lexeme] = true
+
+		if !assignable(fieldType, value) {
+			c.reportSpan(field.Value.Span(), fmt.Sprintf("Cannot assign %s to field '%s' of type %s.", value, field.Name.Lexeme, fieldType))
+		}
+	}
+
+	return structType
+}
+
+// checkField checks accessing the field name
+// of object and returns the field's type.
+func (c *Checker) checkField(object models.Expr, name models.Token) *Type {
+	objectType := c.checkExpr(object)
+	if objectType.Kind == Invalid {
+		return invalidType
+	}
+
+	if objectType.Kind != Struct {
+		c.reportError(name, fmt.Sprintf("Type %s has no field '%s'.", objectType, name.Lexeme))
+		return invalidType
+	}
+
+	fieldType := objectType.field(name.Lexeme)
+	if fieldType == nil {
+		c.reportError(name, fmt.Sprintf("Struct '%s' has no field '%s'.", objectType.Name, name.Lexeme))
+		return invalidType
+	}
+
+	return fieldType
+}
+
+func (c *Checker) checkSetExpr(expr models.SetExpr) *Type {
+	fieldType := c.checkField(expr.Object, expr.Name)
+	value := c.checkCompound(expr.Operator, fieldType, c.checkExpr(expr.Value), expr.Span())
+
+	if !assignable(fieldType, value) {
+		c.reportSpan(expr.Value.Span(), fmt.Sprintf("Cannot assign %s to field '%s' of type %s.", value, expr.Name.Lexeme, fieldType))
+	}
+
+	return fieldType
+}
+
+func (c *Checker) checkListExpr(expr models.ListExpr) *Type {
+	types := make([]*Type, len(expr.Elements))
+	for i, element := range expr.Elements {
+		types[i] = c.checkExpr(element)
+	}
+
+	elem := firstNonNull(types)
+	for i, element := range expr.Elements {
+		if !assignable(elem, types[i]) {
+			c.reportSpan(element.Span(), fmt.Sprintf("List elements must all be %s, got %s.", elem, types[i]))
+		}
+	}
+
+	return listOf(elem)
+}
+
+// firstNonNull returns the first of types that isn't null,
+// which decides the element type of a literal. It's null if
+// every one is, and invalid if there are none.
+func firstNonNull(types []*Type) *Type {
+	elem := invalidType
+	for _, t := range types {
+		if elem.Kind == Invalid || elem.Kind == Null {
+			elem = t
+		}
+	}
+	return elem
+}
+
+// checkMapExpr checks a map literal. Like a list
+// literal, its first entry decides its types.
+func (c *Checker) checkMapExpr(expr models.MapExpr) *Type {
+	key := invalidType
+	values := make([]*Type, len(expr.Entries))
+	for i, entry := range expr.Entries {
+		keyType := c.checkExpr(entry.Key)
+		if key.Kind == Invalid {
+			key = keyType
+			c.checkKeyType(key, entry.Key.Span())
+		} else if !assignable(key, keyType) {
+			c.reportSpan(entry.Key.Span(), fmt.Sprintf("Map keys must all be %s, got %s.", key, keyType))
+		}
+		values[i] = c.checkExpr(entry.Value)
+	}
+
+	elem := firstNonNull(values)
+	for i, entry := range expr.Entries {
+		if !assignable(elem, values[i]) {
+			c.reportSpan(entry.Value.Span(), fmt.Sprintf("Map values must all be %s, got %s.", elem, values[i]))
+		}
+	}
+
+	return mapOf(key, elem)
+}
+
+// checkIndex checks indexing object with index
+// and returns the type of the element.
+func (c *Checker) checkIndex(object models.Expr, index models.Expr) *Type {
+	objectType := c.checkExpr(object)
+	indexType := c.checkExpr(index)
+
+	switch objectType.Kind {
+	case Invalid:
+		return invalidType
+	case List:
+		if !assignable(intType, indexType) {
+			c.reportSpan(index.Span(), fmt.Sprintf("List index must be int, got %s.", indexType))
+		}
+		return objectType.Elem
+	case Map:
+		if !assignable(objectType.Key, indexType) {
+			c.reportSpan(index.Span(), fmt.Sprintf("Map key must be %s, got %s.", objectType.Key, indexType))
+		}
+		return objectType.Elem
+	}
+
+	c.reportSpan(object.Span(), fmt.Sprintf("Cannot index value of type %s.", objectType))
+	return invalidType
+}
+
+func (c *Checker) checkIndexSetExpr(expr models.IndexSetExpr) *Type {
+	elem := c.checkIndex(expr.Object, expr.Index)
+	value := c.checkCompound(expr.Operator, elem, c.checkExpr(expr.Value), expr.Span())
+
+	if !assignable(elem, value) {
+		c.reportSpan(expr.Value.Span(), fmt.Sprintf("Cannot assign %s to element of type %s.", value, elem))
+	}
+
+	return elem
+}
+
+func (c *Checker) checkLiteralExpr(expr models.LiteralExpr) *Type {
+	switch expr.Literal.(type) {
+	case int64:
+		return intType
+	case float64:
+		return doubleType
+	case string:
+		return stringType
+	case bool:
+		return boolType
+	case nil:
+		return nullType
+	}
+
+	return invalidType
+}
+
+func (c *Checker) checkVarExpr(expr models.VarExpr) *Type {
+	t := c.lookupVariable(expr.Name)
+	if t.Overloads != nil {
+		c.reportError(expr.Name, fmt.Sprintf("'%s' is overloaded, so it can only be called.", expr.Name.Lexeme))
+		return invalidType
+	}
+
+	return t
+}
+
+// lookupVariable returns the type of the variable
+// name, reporting it if there isn't one.
+func (c *Checker) lookupVariable(name models.Token) *Type {
+	t := lookupType(name.Lexeme, c.currScope)
+	if t == nil {
+		c.reportError(name, fmt.Sprintf("Undefined variable '%s'.", name.Lexeme), didYouMean(name.Lexeme, c.currScope, false)...)
+		return invalidType
+	}
+
+	return t
+}
+
+func (c *Checker) checkAssignExpr(expr models.AssignExpr) *Type {
+	value := c.checkExpr(expr.Value)
+
+	target := c.lookupVariable(expr.Name)
+	if target.Overloads != nil {
+		c.reportError(expr.Name, fmt.Sprintf("Cannot assign to '%s' because it is overloaded.", expr.Name.Lexeme))
+		return invalidType
+	}
+
+	value = c.checkCompound(expr.Operator, target, value, expr.Span())
+	if !assignable(target, value) {
+		c.reportSpan(expr.Value.Span(), fmt.Sprintf("Cannot assign %s to variable '%s' of type %s.", value, expr.Name.Lexeme, target))
+	}
+
+	return target
+}
+
+func (c *Checker) checkUnaryExpr(expr models.UnaryExpr) *Type {
+	right := c.checkExpr(expr.Right)
+	if right.Kind == Invalid {
+		return invalidType
+	}
+
+	switch expr.Operator.Type {
+	case models.MINUS:
+		if !isNumeric(right) {
+			c.reportError(expr.Operator, fmt.Sprintf("Operand of '-' must be int or double, got %s.", right))
+			return invalidType
+		}
+		return right
+	case models.BANG:
+		if right.Kind != Bool {
+			c.reportError(expr.Operator, fmt.Sprintf("Operand of '!' must be bool, got %s.", right))
+			return invalidType
+		}
+		return boolType
+	}
+
+	return invalidType
+}
+
+func (c *Checker) checkBinaryExpr(expr models.BinaryExpr) *Type {
+	left := c.checkExpr(expr.Left)
+	right := c.checkExpr(expr.Right)
+	if left.Kind == Invalid || right.Kind == Invalid {
+		if isComparison(expr.Operator.Type) {
+			return boolType
+		}
+		return invalidType
+	}
+
+	switch expr.Operator.Type {
+	case models.EQUAL_EQUAL, models.BANG_EQUAL:
+		// Only the types that can hold null can equal it.
+		var comparable bool
+		switch {
+		case left.Kind == Null:
+			comparable = nullable(right)
+		case right.Kind == Null:
+			comparable = nullable(left)
+		default:
+			comparable = sameType(left, right)
+		}
+		if !comparable {
+			c.reportError(expr.Operator, fmt.Sprintf("Cannot compare %s and %s.", left, right))
+		}
+		return boolType
+	case models.LESS, models.LESS_EQUAL, models.GREATER, models.GREATER_EQUAL:
+		if !isNumeric(left) || !sameType(left, right) {
+			c.reportError(expr.Operator, fmt.Sprintf("Cannot compare %s and %s with '%s'.", left, right, expr.Operator.Lexeme))
+		}
+		return boolType
+	case models.PLUS, models.MINUS, models.STAR, models.SLASH, models.PERCENT:
+		return c.checkArithmetic(expr.Operator, left, right, expr.Span())
+	}
+
+	return invalidType
+}
+
+// checkArithmetic returns the type of applying the arithmetic
+// operator to left and right, and reports an error at span
+// if they can't be combined. '+' also joins strings. Nothing
+// is converted, so an int and a double can't be mixed.
+func (c *Checker) checkArithmetic(operator models.Token, left *Type, right *Type, span models.Span) *Type {
+	if left.Kind == Invalid || right.Kind == Invalid {
+		return invalidType
+	}
+
+	concat := operator.Type == models.PLUS && left.Kind == String
+	if !(isNumeric(left) || concat) || !sameType(left, right) {
+		c.reportSpan(span, fmt.Sprintf("Invalid operands to '%s': %s and %s.", operator.Lexeme, left, right))
+		return invalidType
+	}
+	return left
+}
+
+// checkCompound returns the type of the value a compound
+// assignment with operator stores in a target of type
+// target, or value itself if operator is nil.
+func (c *Checker) checkCompound(operator *models.Token, target *Type, value *Type, span models.Span) *Type {
+	if operator == nil {
+		return value
+	}
+
+	// x++ adds the int 1, so it needs an int x.
+	increment := operator.Lexeme == "++" || operator.Lexeme == "--"
+	if increment && target.Kind != Int && target.Kind != Invalid {
+		c.reportSpan(span, fmt.Sprintf("'%s' only works on ints, not %s.", operator.Lexeme, target))
+		return invalidType
+	}
+	return c.checkArithmetic(*operator, target, value, span)
+}
+
+func (c *Checker) checkLogicExpr(expr models.LogicExpr) *Type {
+	left := c.checkExpr(expr.Left)
+	right := c.checkExpr(expr.Right)
+
+	if !assignable(boolType, left) || !assignable(boolType, right) {
+		c.reportError(expr.Operator, fmt.Sprintf("Operands of '%s' must be bool, got %s and %s.", expr.Operator.Lexeme, left, right))
+	}
+
+	return boolType
+}
+
+func (c *Checker) checkCallExpr(expr models.CallExpr) *Type {
+	// Overloaded functions can only be called by
+	// name, so only then is one looked up as is.
+	var callee *Type
+	name, byName := expr.Callee.(models.VarExpr)
+	if byName {
+		callee = c.lookupVariable(name.Name)
+	} else {
+		callee = c.checkExpr(expr.Callee)
+	}
+
+	var arguments []*Type
+	for _, argument := range expr.Arguments {
+		arguments = append(arguments, c.checkExpr(argument))
+	}
+
+	if callee.Kind == Invalid {
+		return invalidType
+	}
+
+	if callee.Overloads != nil {
+		overload, ok := c.resolveOverload(expr, callee, arguments)
+		if !ok {
+			return invalidType
+		}
+		name.Binding.Overload = overload
+		return callee.Overloads[overload].Return
+	}
+
+	if callee.Kind != Func {
+		c.reportSpan(expr.Callee.Span(), fmt.Sprintf("Cannot call value of type %s.", callee))
+		return invalidType
+	}
+
+	if callee.Builtin != "" {
+		return builtins[callee.Builtin](c, expr, arguments)
+	}
+
+	if len(arguments) != len(callee.Params) {
+		c.reportError(expr.Paren, fmt.Sprintf("Expected %s but got %d.", models.Arguments(len(callee.Params)), len(arguments)))
+		return callee.Return
+	}
+
+	for i, argument := range arguments {
+		if !assignable(callee.Params[i], argument) {
+			c.reportSpan(expr.Arguments[i].Span(), fmt.Sprintf("Cannot use %s as argument %d of type %s.", argument, i+1, callee.Params[i]))
+		}
+	}
+
+	return callee.Return
+}
+
+// resolveOverload returns the index of the overload of
+// function that best matches arguments. The best match is
+// the one the most arguments have exactly the type of.
+// If there's no single best match, it's reported.
+func (c *Checker) resolveOverload(expr models.CallExpr, function *Type, arguments []*Type) (int, bool) {
+	best, bestScore, tied := 0, -1, false
+	for i, overload := range function.Overloads {
+		score := matchScore(overload, arguments)
+		if score > bestScore {
+			best, bestScore, tied = i, score, false
+		} else if score == bestScore && score >= 0 {
+			tied = true
+		}
+	}
+
+	if bestScore >= 0 && !tied {
+		return best, true
+	}
+
+	// An argument that's already wrong would
+	// only make the error below confusing.
+	for _, argument := range arguments {
+		if argument.Kind == Invalid {
+			return 0, false
+		}
+	}
+
+	var types []string
+	for _, argument := range arguments {
+		types = append(types, argument.String())
+	}
+	var candidates []string
+	for _, overload := range function.Overloads {
+		candidates = append(candidates, "Candidate: func "+function.Name+strings.TrimPrefix(overload.String(), "func"))
+	}
+
+	if bestScore < 0 {
+		c.reportError(expr.Paren, fmt.Sprintf("No overload of '%s' accepts (%s).", function.Name, strings.Join(types, ", ")), candidates...)
+	} else {
+		c.reportError(expr.Paren, fmt.Sprintf("Call to '%s' with (%s) is ambiguous.", function.Name, strings.Join(types, ", ")), candidates...)
+	}
+	return 0, false
+}
+
+// matchScore returns how many of arguments have exactly the
+// type of their parameter in function, or -1 if they can't
+// all be passed to it.
+func matchScore(function *Type, arguments []*Type) int {
+	if len(arguments) != len(function.Params) {
+		return -1
+	}
+
+	score := 0
+	for i, argument := range arguments {
+		if !assignable(function.Params[i], argument) {
+			return -1
+		}
+		if sameType(function.Params[i], argument) {
+			score++
+		}
+	}
+	return score
+}
+
+func isComparison(tokenType models.TokenType) bool {
+	switch tokenType {
+	case models.EQUAL_EQUAL, models.BANG_EQUAL, models.LESS, models.LESS_EQUAL, models.GREATER, models.GREATER_EQUAL:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,351 @@
+package checker_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+// checkTests pairs sources with the exact errors the
+// checker reports for them, in order. Sources with no
+// errors have a nil want.
+var checkTests = []struct {
+	name   string
+	source string
+	want   []string
+}{
+	{
+		name:   "nested empty list literal",
+		source: `list<list<int>> xs = [[]];`,
+	},
+	{
+		name:   "empty list among nested elements",
+		source: `list<list<int>> xs = [[1], []];`,
+	},
+	{
+		name:   "nested literal of the wrong type",
+		source: `list<list<int>> xs = [["a"]];`,
+		want:   []string{"[Line 1:22] Error: Cannot assign list<list<string>> to variable 'xs' of type list<list<int>>."},
+	},
+	{
+		name: "nested empty literal in a variable",
+		source: `list<list<int>> xs = [];
+list<int> ys = xs;`,
+		want: []string{"[Line 2:16] Error: Cannot assign list<list<int>> to variable 'ys' of type list<int>."},
+	},
+	{
+		name:   "empty list as a map value",
+		source: `map<string, list<int>> m = {"a": []};`,
+	},
+	{
+		name:   "empty map as a map value",
+		source: `map<string, map<string, int>> m = {"a": {}};`,
+	},
+	{
+		name:   "null as a map value",
+		source: `map<string, list<int>> m = {"a": null};`,
+	},
+	{
+		name:   "null map value that can't be null",
+		source: `map<string, int> m = {"a": null};`,
+		want:   []string{"[Line 1:22] Error: Cannot assign map<string, null> to variable 'm' of type map<string, int>."},
+	},
+	{
+		name:   "map key that can't be hashed",
+		source: `map<list<int>, int> m;`,
+		want:   []string{"[Line 1:5] Error: Map keys must be int, double, string or bool, not list<int>."},
+	},
+	{
+		name:   "int compared with null",
+		source: `print(1 == null);`,
+		want:   []string{"[Line 1:9] Error: Cannot compare int and null."},
+	},
+	{
+		name:   "null compared with a string",
+		source: `print(null != "a");`,
+		want:   []string{"[Line 1:12] Error: Cannot compare null and string."},
+	},
+	{
+		name: "list compared with null",
+		source: `list<int> xs;
+print(xs == null, null == xs);`,
+	},
+	{
+		name:   "null compared with null",
+		source: `print(null == null);`,
+	},
+	{
+		name: "one argument expected",
+		source: `func f(int a) {}
+f(1, 2);`,
+		want: []string{"[Line 2:7] Error: Expected 1 argument but got 2."},
+	},
+	{
+		name: "two arguments expected",
+		source: `func f(int a, int b) {}
+f(1);`,
+		want: []string{"[Line 2:4] Error: Expected 2 arguments but got 1."},
+	},
+	{
+		name:   "one argument expected by a builtin",
+		source: `pop();`,
+		want:   []string{"[Line 1:5] Error: Expected 1 argument to 'pop' but got 0."},
+	},
+	{
+		name:   "null int",
+		source: `int x = null;`,
+		want:   []string{"[Line 1:9] Error: Cannot assign null to variable 'x' of type int."},
+	},
+	{
+		name:   "null list element that can't be null",
+		source: `list<int> xs = [1, null];`,
+		want:   []string{"[Line 1:20] Error: List elements must all be int, got null."},
+	},
+	{
+		name:   "null list element that can be null",
+		source: `list<list<int>> xs = [null];`,
+	},
+	{
+		name: "null struct and function",
+		source: `struct P { int x; }
+P p = null;
+func() f = null;`,
+	},
+	{
+		name: "string without a value",
+		source: `string s;
+print(s == "");`,
+	},
+	{
+		name:   "double assigned to an int",
+		source: `int x = 1.5;`,
+		want:   []string{"[Line 1:9] Error: Cannot assign double to variable 'x' of type int."},
+	},
+	{
+		name:   "undefined variable",
+		source: `print(y);`,
+		want:   []string{"[Line 1:7] Error: Undefined variable 'y'."},
+	},
+	{
+		name: "variable used outside its block",
+		source: `{
+	int a = 1;
+	print(a);
+}
+print(a);`,
+		want: []string{"[Line 5:7] Error: Undefined variable 'a'."},
+	},
+	{
+		name: "variable used before its declaration",
+		source: `print(z);
+int z = 1;`,
+	},
+	{
+		name:   "variable read in its own initializer",
+		source: `int x = x + 1;`,
+	},
+	{
+		name: "overloads picked by argument type",
+		source: `func f(int a) int { return a; }
+func f(string s) int { return 0; }
+print(f(1), f("a"));`,
+	},
+	{
+		name: "no overload for the arguments",
+		source: `func f(int a) int { return a; }
+func f(string s) int { return 0; }
+f(true);`,
+		want: []string{"[Line 3:7] Error: No overload of 'f' accepts (bool)."},
+	},
+	{
+		name: "overloaded function used as a value",
+		source: `func f(int a) int { return a; }
+func f(string s) int { return 0; }
+func(int) int g = f;`,
+		want: []string{"[Line 3:19] Error: 'f' is overloaded, so it can only be called."},
+	},
+	{
+		name:   "function missing a return",
+		source: `func f() int { }`,
+		want:   []string{"[Line 1:6] Error: Function 'f' must return a value of type int on every path."},
+	},
+	{
+		name: "function returning on every branch",
+		source: `func f(bool b) int {
+	if (b) {
+		return 1;
+	} else {
+		return 2;
+	}
+}`,
+	},
+	{
+		name:   "return of the wrong type",
+		source: `func f() int { return "a"; }`,
+		want:   []string{"[Line 1:23] Error: Cannot return string from a function returning int."},
+	},
+	{
+		name: "struct literal with an unknown field",
+		source: `struct P { int x; }
+P p = P{x: 1, y: 2};`,
+		want: []string{"[Line 2:15] Error: Struct 'P' has no field 'y'."},
+	},
+	{
+		name: "struct literal with a field of the wrong type",
+		source: `struct P { int x; string name; }
+P p = P{x: "1", name: "a"};`,
+		want: []string{"[Line 2:12] Error: Cannot assign string to field 'x' of type int."},
+	},
+	{
+		name: "struct literal setting a field twice",
+		source: `struct P { int x; }
+P p = P{x: 1, x: 2};`,
+		want: []string{"[Line 2:15] Error: Field 'x' is set more than once."},
+	},
+	{
+		name: "reading an unknown field",
+		source: `struct P { int x; }
+P p = P{x: 1};
+print(p.z);`,
+		want: []string{"[Line 3:9] Error: Struct 'P' has no field 'z'."},
+	},
+	{
+		name: "assigning a field the wrong type",
+		source: `struct P { int x; }
+P p = P{x: 1};
+p.x = true;`,
+		want: []string{"[Line 3:7] Error: Cannot assign bool to field 'x' of type int."},
+	},
+	{
+		name: "field of a value that isn't a struct",
+		source: `int n = 1;
+print(n.x);`,
+		want: []string{"[Line 2:9] Error: Type int has no field 'x'."},
+	},
+	{
+		name:   "duplicate field declaration",
+		source: `struct P { int x; double x; }`,
+		want:   []string{"[Line 1:26] Error: Duplicate field 'x' in struct 'P'."},
+	},
+	{
+		name:   "unknown struct",
+		source: `print(Pint{x: 1});`,
+		want:   []string{"[Line 1:7] Error: Unknown struct 'Pint'."},
+	},
+	{
+		name: "struct fields of their own type",
+		source: `struct Node { int value; Node next; }
+Node n = Node{value: 1, next: Node{value: 2}};
+n.next.next = n;
+print(n.next.value);`,
+	},
+}
+
+func TestCheck(t *testing.T) {
+	for _, test := range checkTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := check(t, test.source)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d errors, want %d\ngot:  %q\nwant: %q", len(got), len(test.want), got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("error %d = %q, want %q", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+// check parses source, which must parse cleanly,
+// and returns the checker's errors as strings.
+func check(t *testing.T, source string) []string {
+	t.Helper()
+
+	tokens, errs := scanner.Scan(source)
+	if len(errs) > 0 {
+		t.Fatalf("scan: %v", errs)
+	}
+	stmts, errs := parser.Parse(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse: %v", errs)
+	}
+
+	var messages []string
+	for _, err := range checker.Check(stmts) {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// TestRenderedSpans checks that errors after multi-byte
+// characters point at the right column and underline
+// every character of the code they're about.
+func TestRenderedSpans(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "after multi-byte characters",
+			source: `string s = "ñé"; int x = "héllo";`,
+			want: `error: Cannot assign string to variable 'x' of type int.
+ --> test.harp:1:26
+  |
+1 | string s = "ñé"; int x = "héllo";
+  |                          ^^^^^^^
+
+`,
+		},
+		{
+			name:   "multi-byte characters in the span",
+			source: "\t int ñ = \"ab\" + \"ñc\";",
+			want: "error: Cannot assign string to variable 'ñ' of type int.\n" +
+				" --> test.harp:1:11\n" +
+				"  |\n" +
+				"1 | \t int ñ = \"ab\" + \"ñc\";\n" +
+				"  | \t         ^^^^^^^^^^^\n" +
+				"\n",
+		},
+		{
+			name: "on a later line",
+			source: `string ñé = "ñé";
+print(ñé + 1);`,
+			want: `error: Invalid operands to '+': string and int.
+ --> test.harp:2:7
+  |
+2 | print(ñé + 1);
+  |       ^^^^^^
+
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, errs := scanner.Scan(test.source)
+			if len(errs) > 0 {
+				t.Fatalf("scan: %v", errs)
+			}
+			stmts, errs := parser.Parse(tokens)
+			if len(errs) > 0 {
+				t.Fatalf("parse: %v", errs)
+			}
+			errs = checker.Check(stmts)
+			if len(errs) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+			}
+
+			var out bytes.Buffer
+			diagnostics.NewRenderer(&out, "test.harp", test.source).Render(diagnostics.From(errs[0]))
+			if out.String() != test.want {
+				t.Errorf("rendered:\n%s\nwant:\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+)
+
+func (c *Checker) reportError(token models.Token, message string, help ...string) {
+	c.reportSpan(token.Span(), message, help...)
+}
+
+// reportSpan reports an error about all of span,
+// such as a whole expression of the wrong type.
+func (c *Checker) reportSpan(span models.Span, message string, help ...string) {
+	c.checkErrors = append(c.checkErrors, &CheckError{
+		Line:    span.Start.Line,
+		Column:  span.Start.Column,
+		Length:  span.Width(),
+		Message: message,
+		Help:    help,
+	})
+}
+
+type CheckError struct {
+	Line    int
+	Column  int
+	Length  int
+	Message string
+	// Help holds suggestions for fixing the error.
+	Help []string
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("[Line %d:%d] Error: %s", e.Line, e.Column, e.Message)
+}
+
+func (e *CheckError) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Line,
+		Column:   e.Column,
+		Length:   e.Length,
+		Message:  e.Message,
+		Help:     e.Help,
+	}
+}
@@ -0,0 +1,110 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+)
+
+type Scope struct {
+	types map[string]*Type
+	// functions holds the overloads of each function
+	// declared in the scope, in the order declared.
+	functions map[string][]*Type
+	parent    *Scope
+}
+
+func defineType(name string, t *Type, currentScope *Scope) {
+	currentScope.types[name] = t
+}
+
+// declareFunction adds function as an overload of name in
+// currentScope and returns its index. A function with the
+// same parameters as an earlier overload replaces it.
+func declareFunction(name string, function *Type, currentScope *Scope) int {
+	if currentScope.functions == nil {
+		currentScope.functions = map[string][]*Type{}
+	}
+
+	// A function declared over something else starts afresh.
+	overloads := currentScope.functions[name]
+	if existing, ok := currentScope.types[name]; !ok || existing.Kind != Func || len(overloads) == 0 {
+		overloads = nil
+	}
+
+	index := len(overloads)
+	for i, overload := range overloads {
+		if sameParams(overload, function) {
+			index = i
+			break
+		}
+	}
+	if index == len(overloads) {
+		overloads = append(overloads, function)
+	} else {
+		overloads[index] = function
+	}
+	currentScope.functions[name] = overloads
+
+	if len(overloads) == 1 {
+		defineType(name, function, currentScope)
+	} else {
+		defineType(name, &Type{Kind: Func, Name: name, Overloads: overloads}, currentScope)
+	}
+	return index
+}
+
+// declaredName returns the name stmt declares,
+// if it's a declaration.
+func declaredName(stmt models.Stmt) (string, bool) {
+	switch stmt := stmt.(type) {
+	case models.VarStmt:
+		return stmt.Name.Lexeme, true
+	case models.FuncStmt:
+		return stmt.Name.Lexeme, true
+	case models.StructStmt:
+		return stmt.Name.Lexeme, true
+	}
+	return "", false
+}
+
+func lookupType(name string, currentScope *Scope) *Type {
+	if t, ok := currentScope.types[name]; ok {
+		return t
+	}
+
+	if currentScope.parent != nil {
+		return lookupType(name, currentScope.parent)
+	}
+
+	return nil
+}
+
+// visibleNames returns every name defined in currentScope
+// and the scopes around it, or only the struct names if
+// structs is true.
+func visibleNames(currentScope *Scope, structs bool) []string {
+	var names []string
+	for scope := currentScope; scope != nil; scope = scope.parent {
+		for name, t := range scope.types {
+			if !structs || t.Kind == StructDef {
+				names = append(names, name)
+			}
+		}
+	}
+	// Map order is random, so sort for stable suggestions.
+	sort.Strings(names)
+	return names
+}
+
+// didYouMean returns help suggesting the name in scope
+// closest to name, or nothing if none is close enough.
+// If structs is true, only struct names are suggested.
+func didYouMean(name string, currentScope *Scope, structs bool) []string {
+	if suggestion, ok := diagnostics.Suggest(name, visibleNames(currentScope, structs)); ok {
+		return []string{fmt.Sprintf("Did you mean '%s'?", suggestion)}
+	}
+	return nil
+}
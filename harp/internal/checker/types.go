@@ -0,0 +1,325 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+type Kind int
+
+const (
+	Invalid Kind = iota
+	Int
+	Double
+	String
+	Bool
+	Null
+	Void
+	Func
+	List
+	Map
+	Range
+	Struct
+	// StructDef is the type of a struct's name, which
+	// holds the struct type in Elem.
+	StructDef
+	// Any is only used for the parameters of builtins,
+	// which accept values of every type.
+	Any
+)
+
+// Type is the static type of a value.
+type Type struct {
+	Kind   Kind
+	Name   string
+	Fields []Field
+	// Elem is the element type of a list
+	// or the value type of a map.
+	Elem   *Type
+	Key    *Type
+	Params []*Type
+	Return *Type
+	// Builtin is the name of the builtin function this
+	// type belongs to. Builtins are checked by the rules
+	// in builtins.go instead of by Params and Return.
+	Builtin string
+	// Overloads holds each function declared with Name in
+	// the same scope when there's more than one. Calls
+	// pick one of them by the types of their arguments.
+	Overloads []*Type
+}
+
+type Field struct {
+	Name string
+	Type *Type
+}
+
+var invalidType = &Type{Kind: Invalid}
+var intType = &Type{Kind: Int}
+var doubleType = &Type{Kind: Double}
+var stringType = &Type{Kind: String}
+var boolType = &Type{Kind: Bool}
+var nullType = &Type{Kind: Null}
+var voidType = &Type{Kind: Void}
+var anyType = &Type{Kind: Any}
+var rangeType = &Type{Kind: Range}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Int:
+		return "int"
+	case Double:
+		return "double"
+	case String:
+		return "string"
+	case Bool:
+		return "bool"
+	case Null:
+		return "null"
+	case Void:
+		return "void"
+	case List:
+		return "list<" + t.Elem.String() + ">"
+	case Map:
+		return "map<" + t.Key.String() + ", " + t.Elem.String() + ">"
+	case Range:
+		return "range"
+	case Struct:
+		return t.Name
+	case StructDef:
+		return "struct " + t.Elem.Name
+	case Any:
+		return "any"
+	case Func:
+		if t.Builtin != "" {
+			return "builtin " + t.Builtin
+		}
+		if t.Overloads != nil {
+			return "overloaded func " + t.Name
+		}
+		var params []string
+		for _, param := range t.Params {
+			params = append(params, param.String())
+		}
+		if t.Return.Kind == Void {
+			return "func(" + strings.Join(params, ", ") + ")"
+		}
+		return "func(" + strings.Join(params, ", ") + ") " + t.Return.String()
+	}
+
+	return "invalid"
+}
+
+// resolveType returns the type named by a type
+// written in source, reporting unknown names.
+func (c *Checker) resolveType(typeExpr models.TypeExpr) *Type {
+	switch typeExpr.Name.Type {
+	case models.INT_VAR:
+		return intType
+	case models.DOUBLE_VAR:
+		return doubleType
+	case models.STRING_VAR:
+		return stringType
+	case models.BOOL_VAR:
+		return boolType
+	case models.LIST_VAR:
+		if len(typeExpr.Args) != 1 {
+			return invalidType
+		}
+		return listOf(c.resolveType(typeExpr.Args[0]))
+	case models.MAP_VAR:
+		if len(typeExpr.Args) != 2 {
+			return invalidType
+		}
+		key := c.resolveType(typeExpr.Args[0])
+		c.checkKeyType(key, typeExpr.Args[0].Span())
+		return mapOf(key, c.resolveType(typeExpr.Args[1]))
+	case models.IDENTIFIER:
+		if typeExpr.Name.Lexeme == "range" {
+			return rangeType
+		}
+		named := lookupType(typeExpr.Name.Lexeme, c.currScope)
+		if named == nil || named.Kind != StructDef {
+			c.reportError(typeExpr.Name, fmt.Sprintf("Unknown type '%s'.", typeExpr.Name.Lexeme), didYouMean(typeExpr.Name.Lexeme, c.currScope, true)...)
+			return invalidType
+		}
+		return named.Elem
+	case models.FUNC:
+		function := &Type{Kind: Func, Return: voidType}
+		for _, param := range typeExpr.Args {
+			function.Params = append(function.Params, c.resolveType(param))
+		}
+		if typeExpr.Return != nil {
+			function.Return = c.resolveType(*typeExpr.Return)
+		}
+		return function
+	}
+
+	return invalidType
+}
+
+// field returns the type of the field name
+// of struct type t, or nil if it has none.
+func (t *Type) field(name string) *Type {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field.Type
+		}
+	}
+	return nil
+}
+
+// ValueType returns the runtime view of t.
+func (t *Type) ValueType() models.ValueType {
+	switch t.Kind {
+	case Int:
+		return models.ValueType{Kind: models.IntKind}
+	case Double:
+		return models.ValueType{Kind: models.DoubleKind}
+	case String:
+		return models.ValueType{Kind: models.StringKind}
+	case Bool:
+		return models.ValueType{Kind: models.BoolKind}
+	case List:
+		elem := t.Elem.ValueType()
+		return models.ValueType{Kind: models.ListKind, Elem: &elem}
+	case Map:
+		key, elem := t.Key.ValueType(), t.Elem.ValueType()
+		return models.ValueType{Kind: models.MapKind, Key: &key, Elem: &elem}
+	case Range:
+		return models.ValueType{Kind: models.RangeKind}
+	case Struct:
+		return models.ValueType{Kind: models.StructKind, Name: t.Name}
+	case Func:
+		return models.ValueType{Kind: models.FuncKind}
+	case Void:
+		return models.ValueType{Kind: models.VoidKind}
+	}
+
+	return models.ValueType{Kind: models.AnyKind}
+}
+
+// Signature returns the runtime signature of
+// function type t, which mustn't be a builtin.
+func (t *Type) Signature() models.Signature {
+	signature := models.Signature{Return: t.Return.ValueType()}
+	for _, param := range t.Params {
+		signature.Params = append(signature.Params, param.ValueType())
+	}
+	return signature
+}
+
+func listOf(elem *Type) *Type {
+	return &Type{Kind: List, Elem: elem}
+}
+
+func mapOf(key *Type, elem *Type) *Type {
+	return &Type{Kind: Map, Key: key, Elem: elem}
+}
+
+// isHashable reports whether values of type t
+// can be map keys.
+func isHashable(t *Type) bool {
+	switch t.Kind {
+	case Int, Double, String, Bool, Invalid:
+		return true
+	}
+	return false
+}
+
+// checkKeyType reports key if it can't be the key
+// type of a map. Span is where the key type is.
+func (c *Checker) checkKeyType(key *Type, span models.Span) {
+	if !isHashable(key) {
+		c.reportSpan(span, fmt.Sprintf("Map keys must be int, double, string or bool, not %s.", key))
+	}
+}
+
+// sameType reports whether a and b are the same type.
+func sameType(a *Type, b *Type) bool {
+	if a.Kind != b.Kind {
+		return false
+	}
+
+	// Struct types are only the same as themselves.
+	if a.Kind == Struct || a.Kind == StructDef {
+		return a == b
+	}
+
+	if a.Kind == List {
+		return sameType(a.Elem, b.Elem)
+	}
+
+	if a.Kind == Map {
+		return sameType(a.Key, b.Key) && sameType(a.Elem, b.Elem)
+	}
+
+	if a.Kind == Func {
+		if a.Builtin != "" || b.Builtin != "" {
+			return a.Builtin == b.Builtin
+		}
+		return sameParams(a, b) && sameType(a.Return, b.Return)
+	}
+
+	return true
+}
+
+// sameParams reports whether functions a and
+// b take the same types of parameters.
+func sameParams(a *Type, b *Type) bool {
+	if len(a.Params) != len(b.Params) {
+		return false
+	}
+	for i := range a.Params {
+		if !sameType(a.Params[i], b.Params[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// assignable reports whether a value of type value
+// can be stored in a variable of type target.
+func assignable(target *Type, value *Type) bool {
+	if target.Kind == Invalid || value.Kind == Invalid {
+		return true
+	}
+
+	if value.Kind == Null {
+		return nullable(target)
+	}
+
+	if target.Kind == Any {
+		return value.Kind != Void
+	}
+
+	// An empty list or map literal has unknown element
+	// types and can be stored in any list or map, and one
+	// of only nulls in any that can hold null. Literals
+	// nest, so the same goes for their elements.
+	if target.Kind == List && value.Kind == List {
+		return assignable(target.Elem, value.Elem)
+	}
+	if target.Kind == Map && value.Kind == Map {
+		return assignable(target.Key, value.Key) && assignable(target.Elem, value.Elem)
+	}
+
+	return sameType(target, value)
+}
+
+// nullable reports whether a variable of type t can
+// hold null. Ints, doubles, strings and bools can't, so
+// using one never finds null in it at runtime.
+func nullable(t *Type) bool {
+	switch t.Kind {
+	case List, Map, Struct, Func, Any, Null, Invalid:
+		return true
+	}
+	return false
+}
+
+func isNumeric(t *Type) bool {
+	return t.Kind == Int || t.Kind == Double
+}
@@ -0,0 +1,187 @@
+package compiler
+
+import (
+	"sort"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+type OpCode byte
+
+const (
+	// OpConstant pushes the constant at its 2 byte operand.
+	OpConstant OpCode = iota
+	OpNull
+	OpTrue
+	OpFalse
+	OpPop
+	// OpDup pushes a copy of the value on top of the
+	// stack, and OpDupPair copies of the top two.
+	OpDup
+	OpDupPair
+
+	// OpGetLocal and OpSetLocal take a 2 byte stack slot.
+	OpGetLocal
+	OpSetLocal
+	// The global ops take the 2 byte constant index of a name.
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	// The upvalue ops take a 2 byte upvalue index.
+	OpGetUpvalue
+	OpSetUpvalue
+
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpModulo
+	OpNot
+	OpNegate
+
+	// The jump ops take a 2 byte offset.
+	OpJump
+	OpJumpIfFalse
+	OpLoop
+	// OpIterate takes a 1 byte count of loop variables and
+	// replaces the value on top of the stack with an
+	// iterator over it. OpNext pops an iterator and pushes
+	// the loop variables of its next item, or jumps forward
+	// by its 2 byte offset if there are none left.
+	OpIterate
+	OpNext
+
+	// OpCall takes a 1 byte argument count.
+	OpCall
+	OpReturn
+	// OpClosure takes the 2 byte constant index of a
+	// function, then a 1 byte is-local flag and a 2 byte
+	// index for each variable the function captures.
+	OpClosure
+	// OpCloseUpvalue moves the local on top of the
+	// stack into the upvalues capturing it.
+	OpCloseUpvalue
+
+	// OpList takes a 2 byte element count.
+	OpList
+	// OpMap takes a 2 byte count of the key and
+	// value pairs on top of the stack.
+	OpMap
+	OpGetIndex
+	OpSetIndex
+
+	// OpStruct takes a 1 byte count of the name and
+	// value pairs above the struct on the stack.
+	OpStruct
+	// The field ops take the 2 byte constant index of a name.
+	OpGetField
+	OpSetField
+)
+
+var OpCodeNames = map[OpCode]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNull:         "OP_NULL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpDup:          "OP_DUP",
+	OpDupPair:      "OP_DUP_PAIR",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpEqual:        "OP_EQUAL",
+	OpNotEqual:     "OP_NOT_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpModulo:       "OP_MODULO",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpIterate:      "OP_ITERATE",
+	OpNext:         "OP_NEXT",
+	OpCall:         "OP_CALL",
+	OpReturn:       "OP_RETURN",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpList:         "OP_LIST",
+	OpMap:          "OP_MAP",
+	OpGetIndex:     "OP_GET_INDEX",
+	OpSetIndex:     "OP_SET_INDEX",
+	OpStruct:       "OP_STRUCT",
+	OpGetField:     "OP_GET_FIELD",
+	OpSetField:     "OP_SET_FIELD",
+}
+
+// Chunk is a compiled sequence of instructions.
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	// spans holds the source span the bytes in Code were
+	// compiled from, once for each run of bytes from the
+	// same span, in order.
+	spans []spanRun
+}
+
+// spanRun is a run of bytes in Code compiled from one
+// span. It lasts until the start of the next run.
+type spanRun struct {
+	start int
+	span  models.Span
+}
+
+func (c *Chunk) write(b byte, token models.Token) {
+	c.Code = append(c.Code, b)
+
+	span := token.Span()
+	if len(c.spans) == 0 || c.spans[len(c.spans)-1].span != span {
+		c.spans = append(c.spans, spanRun{start: len(c.Code) - 1, span: span})
+	}
+}
+
+// SpanAt returns the source span the byte
+// at offset in Code was compiled from.
+func (c *Chunk) SpanAt(offset int) models.Span {
+	// Find the last run starting at or before offset.
+	i := sort.Search(len(c.spans), func(i int) bool { return c.spans[i].start > offset })
+	return c.spans[i-1].span
+}
+
+// addConstant adds value to the constant
+// pool and returns its index.
+func (c *Chunk) addConstant(value interface{}) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// Function is a compiled Harp function. The top-level
+// code of a program is compiled to a Function too.
+type Function struct {
+	Name      string
+	Signature models.Signature
+	// UpvalueCount is how many variables the function
+	// captures from the functions around it.
+	UpvalueCount int
+	Chunk        *Chunk
+}
+
+func (f *Function) String() string {
+	return "<func " + f.Name + ">"
+}
@@ -0,0 +1,28 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+func TestSpanRuns(t *testing.T) {
+	token := func(line int) models.Token {
+		return models.Token{Line: line, Column: 1, End: models.Position{Line: line, Column: 2}}
+	}
+
+	var chunk Chunk
+	lines := []int{1, 1, 1, 2, 2, 1, 3}
+	for _, line := range lines {
+		chunk.write(byte(OpNull), token(line))
+	}
+
+	if len(chunk.spans) != 4 {
+		t.Errorf("got %d span runs, want 4", len(chunk.spans))
+	}
+	for offset, line := range lines {
+		if got := chunk.SpanAt(offset); got != token(line).Span() {
+			t.Errorf("SpanAt(%d) = %+v, want line %d", offset, got, line)
+		}
+	}
+}
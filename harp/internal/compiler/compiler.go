@@ -0,0 +1,801 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+// maxLocals is how many local variables fit in the
+// 2 byte slot operand of OpGetLocal and OpSetLocal.
+const maxLocals = math.MaxUint16 + 1
+
+// maxUpvalues is how many variables a function can
+// capture with the 2 byte operand of OpGetUpvalue.
+const maxUpvalues = math.MaxUint16 + 1
+
+// Compiler lowers a syntax tree to bytecode. Each function
+// in the program gets its own Compiler, linked to the one
+// compiling the code around it.
+type Compiler struct {
+	enclosing  *Compiler
+	function   *Function
+	locals     []local
+	upvalues   []upvalue
+	scopeDepth int
+	// loops are the loops around the code being
+	// compiled, innermost last.
+	loops []*loop
+	// token is the token the instructions being
+	// emitted are reported at in runtime errors.
+	token         models.Token
+	compileErrors *[]error
+	// tooMany is set once the function has been reported
+	// for having too many locals or closure variables,
+	// so each limit is only reported once.
+	tooMany bool
+}
+
+// local is a local variable. Its index in
+// Compiler.locals is its stack slot.
+type local struct {
+	name  string
+	depth int
+	// captured is whether a nested function uses the
+	// local, so it must outlive its stack slot.
+	captured bool
+}
+
+// loop is a loop being compiled. Break and continue
+// statements in it jump forward to its end and to its
+// increment, so their jumps are patched once those
+// are compiled.
+type loop struct {
+	label string
+	// scopeDepth is the scope depth around the loop. The
+	// locals of deeper scopes are popped when jumping.
+	scopeDepth int
+	breaks     []int
+	continues  []int
+}
+
+// upvalue is a variable a function captures from the
+// function around it. It's either a local of that
+// function or one of that function's own upvalues.
+type upvalue struct {
+	index   int
+	isLocal bool
+}
+
+// Compile compiles statements to a function holding the
+// top-level code of the program, and returns it with
+// any errors found along the way.
+func Compile(statements []models.Stmt) (*Function, []error) {
+	var compileErrors []error
+	c := newCompiler(nil, "script", &compileErrors)
+
+	for _, stmt := range statements {
+		c.compileStmt(stmt)
+	}
+	c.emitReturn()
+
+	return c.function, compileErrors
+}
+
+func newCompiler(enclosing *Compiler, name string, compileErrors *[]error) *Compiler {
+	return &Compiler{
+		enclosing: enclosing,
+		function:  &Function{Name: name, Chunk: &Chunk{}},
+		// Slot 0 holds the function being called.
+		locals:        []local{{name: "", depth: 0}},
+		compileErrors: compileErrors,
+	}
+}
+
+func (c *Compiler) compileStmt(stmt models.Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Compiler) VisitExprStmt(stmt models.ExprStmt) (interface{}, error) {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OpPop)
+	return nil, nil
+}
+
+func (c *Compiler) VisitBlockStmt(stmt models.BlockStmt) (interface{}, error) {
+	c.beginScope()
+	c.compileBlock(stmt.Statements)
+	c.endScope()
+	return nil, nil
+}
+
+// VisitErrorStmt is never reached, since programs
+// with parse errors aren't compiled.
+func (c *Compiler) VisitErrorStmt(stmt models.ErrorStmt) (interface{}, error) {
+	c.reportError(c.token, "Cannot compile a statement that failed to parse.")
+	return nil, nil
+}
+
+// compileBlock compiles the statements of the innermost
+// scope. The local functions among them get their slots
+// first, holding null until their declarations run, so
+// that their bodies can capture and call each other.
+func (c *Compiler) compileBlock(statements []models.Stmt) {
+	if c.scopeDepth > 0 {
+		for _, stmt := range statements {
+			if stmt, ok := stmt.(models.FuncStmt); ok {
+				c.token = stmt.Name
+				c.emitOp(OpNull)
+				c.addLocal(stmt.Binding.RuntimeName(stmt.Name).Lexeme)
+			}
+		}
+	}
+
+	for _, stmt := range statements {
+		c.compileStmt(stmt)
+	}
+}
+
+func (c *Compiler) VisitVarStmt(stmt models.VarStmt) (interface{}, error) {
+	c.token = stmt.Name
+	if stmt.Initializer != nil {
+		c.compileExpr(stmt.Initializer)
+	} else if zero := models.TypeOf(stmt.Type).Zero(); zero != nil {
+		c.emitConstant(zero)
+	} else {
+		c.emitOp(OpNull)
+	}
+
+	c.defineVariable(stmt.Name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt models.IfStmt) (interface{}, error) {
+	c.compileExpr(stmt.Condition)
+
+	c.token = stmt.Keyword
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.compileStmt(stmt.ThenBranch)
+
+	elseJump := c.emitJump(OpJump)
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+	if stmt.ElseBranch != nil {
+		c.compileStmt(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil, nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt models.WhileStmt) (interface{}, error) {
+	loopStart := len(c.function.Chunk.Code)
+	c.compileExpr(stmt.Condition)
+
+	c.token = stmt.Keyword
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+
+	current := &loop{scopeDepth: c.scopeDepth}
+	if stmt.Label != nil {
+		current.label = stmt.Label.Lexeme
+	}
+	c.loops = append(c.loops, current)
+	c.compileStmt(stmt.Body)
+	c.loops = c.loops[:len(c.loops)-1]
+
+	for _, jump := range current.continues {
+		c.patchJump(jump)
+	}
+	if stmt.Increment != nil {
+		c.compileExpr(stmt.Increment)
+		c.emitOp(OpPop)
+	}
+
+	c.token = stmt.Keyword
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+
+	// Breaks jump past the condition's OpPop,
+	// since they skip the condition.
+	for _, jump := range current.breaks {
+		c.patchJump(jump)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitForEachStmt(stmt models.ForEachStmt) (interface{}, error) {
+	// The iterator lives in a hidden local for the whole loop.
+	c.beginScope()
+	c.compileExpr(stmt.Iterable)
+	c.token = stmt.In
+	c.emitOp(OpIterate)
+	c.emitByte(byte(len(stmt.Vars)))
+	c.addLocal("")
+	iterator := len(c.locals) - 1
+
+	loopStart := len(c.function.Chunk.Code)
+	c.token = stmt.Keyword
+	c.emitOp(OpGetLocal)
+	c.emitShort(iterator)
+	exitJump := c.emitJump(OpNext)
+
+	current := &loop{scopeDepth: c.scopeDepth}
+	if stmt.Label != nil {
+		current.label = stmt.Label.Lexeme
+	}
+
+	// The loop variables are in a scope of their own that
+	// ends every pass, so closures capture each pass's.
+	c.beginScope()
+	for _, v := range stmt.Vars {
+		c.addLocal(v.Name.Lexeme)
+	}
+	c.loops = append(c.loops, current)
+	c.compileStmt(stmt.Body)
+	c.loops = c.loops[:len(c.loops)-1]
+	c.endScope()
+
+	for _, jump := range current.continues {
+		c.patchJump(jump)
+	}
+	c.token = stmt.Keyword
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	for _, jump := range current.breaks {
+		c.patchJump(jump)
+	}
+
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Compiler) VisitBreakStmt(stmt models.BreakStmt) (interface{}, error) {
+	target := c.jumpTarget(stmt.Keyword, stmt.Label)
+	if target != nil {
+		target.breaks = append(target.breaks, c.emitJump(OpJump))
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitContinueStmt(stmt models.ContinueStmt) (interface{}, error) {
+	target := c.jumpTarget(stmt.Keyword, stmt.Label)
+	if target != nil {
+		target.continues = append(target.continues, c.emitJump(OpJump))
+	}
+	return nil, nil
+}
+
+// jumpTarget returns the loop labeled label, or the innermost
+// loop if label is nil, and pops the locals declared inside
+// it, ready for the break or continue at keyword to jump.
+func (c *Compiler) jumpTarget(keyword models.Token, label *models.Token) *loop {
+	c.token = keyword
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if label == nil || c.loops[i].label == label.Lexeme {
+			c.discardLocals(c.loops[i].scopeDepth)
+			return c.loops[i]
+		}
+	}
+
+	// The parser only allows break and continue in loops.
+	c.reportError(keyword, fmt.Sprintf("Cannot use '%s' outside of a loop.", keyword.Lexeme))
+	return nil
+}
+
+func (c *Compiler) VisitFuncStmt(stmt models.FuncStmt) (interface{}, error) {
+	name := stmt.Binding.RuntimeName(stmt.Name)
+	if c.scopeDepth == 0 {
+		c.compileFunction(stmt.Name.Lexeme, stmt.Params, stmt.ReturnType, stmt.Body, stmt.Name)
+		c.defineVariable(name)
+		return nil, nil
+	}
+
+	// A local function's slot was taken by compileBlock,
+	// so the closure is stored into it.
+	c.compileFunction(stmt.Name.Lexeme, stmt.Params, stmt.ReturnType, stmt.Body, stmt.Name)
+	c.emitOp(OpSetLocal)
+	c.emitShort(c.resolveLocal(name.Lexeme))
+	c.emitOp(OpPop)
+	return nil, nil
+}
+
+// compileFunction compiles a function body with its own
+// Compiler and emits a closure over it. The closure and
+// the function's implicit return are reported at token.
+func (c *Compiler) compileFunction(name string, params []models.FuncParam, returnType *models.TypeExpr, body []models.Stmt, token models.Token) {
+	fc := newCompiler(c, name, c.compileErrors)
+	fc.token = token
+	fc.function.Signature = models.SignatureOf(params, returnType)
+
+	// The parameters and body share a scope.
+	fc.beginScope()
+	for _, param := range params {
+		fc.addLocal(param.Name.Lexeme)
+	}
+	fc.compileBlock(body)
+	fc.token = token
+	fc.emitReturn()
+
+	c.token = token
+	c.emitOp(OpClosure)
+	c.emitShort(c.makeConstant(fc.function))
+	for _, upvalue := range fc.upvalues {
+		if upvalue.isLocal {
+			c.emitByte(1)
+		} else {
+			c.emitByte(0)
+		}
+		c.emitShort(upvalue.index)
+	}
+}
+
+func (c *Compiler) VisitStructStmt(stmt models.StructStmt) (interface{}, error) {
+	structType := models.StructOf(stmt)
+
+	c.token = stmt.Name
+	c.emitConstant(structType)
+	c.defineVariable(stmt.Name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt models.ReturnStmt) (interface{}, error) {
+	if c.enclosing == nil {
+		c.reportError(stmt.Keyword, "Cannot return from top-level code.")
+		return nil, nil
+	}
+
+	c.token = stmt.Keyword
+	if stmt.Value != nil {
+		c.compileExpr(stmt.Value)
+	} else {
+		c.emitOp(OpNull)
+	}
+	c.emitOp(OpReturn)
+	return nil, nil
+}
+
+func (c *Compiler) compileExpr(expr models.Expr) {
+	expr.Accept(c)
+}
+
+func (c *Compiler) VisitGroupingExpr(expr models.GroupingExpr) (interface{}, error) {
+	c.compileExpr(expr.Expression)
+	return nil, nil
+}
+
+func (c *Compiler) VisitFuncExpr(expr models.FuncExpr) (interface{}, error) {
+	c.compileFunction("anonymous", expr.Params, expr.ReturnType, expr.Body, expr.Keyword)
+	return nil, nil
+}
+
+// VisitErrorExpr is never reached, since programs
+// with parse errors aren't compiled.
+func (c *Compiler) VisitErrorExpr(expr models.ErrorExpr) (interface{}, error) {
+	c.reportError(c.token, "Cannot compile an expression that failed to parse.")
+	return nil, nil
+}
+
+var binaryOps = map[models.TokenType]OpCode{
+	models.EQUAL_EQUAL:   OpEqual,
+	models.BANG_EQUAL:    OpNotEqual,
+	models.GREATER:       OpGreater,
+	models.GREATER_EQUAL: OpGreaterEqual,
+	models.LESS:          OpLess,
+	models.LESS_EQUAL:    OpLessEqual,
+	models.PLUS:          OpAdd,
+	models.MINUS:         OpSubtract,
+	models.STAR:          OpMultiply,
+	models.SLASH:         OpDivide,
+	models.PERCENT:       OpModulo,
+}
+
+func (c *Compiler) VisitBinaryExpr(expr models.BinaryExpr) (interface{}, error) {
+	c.compileExpr(expr.Left)
+	c.compileExpr(expr.Right)
+
+	c.token = expr.Operator
+	op, ok := binaryOps[expr.Operator.Type]
+	if !ok {
+		c.reportError(expr.Operator, fmt.Sprintf("Unknown operator '%s'.", expr.Operator.Lexeme))
+		return nil, nil
+	}
+	c.emitOp(op)
+	return nil, nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr models.UnaryExpr) (interface{}, error) {
+	c.compileExpr(expr.Right)
+
+	c.token = expr.Operator
+	switch expr.Operator.Type {
+	case models.MINUS:
+		c.emitOp(OpNegate)
+	case models.BANG:
+		c.emitOp(OpNot)
+	default:
+		c.reportError(expr.Operator, fmt.Sprintf("Unknown operator '%s'.", expr.Operator.Lexeme))
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr models.LiteralExpr) (interface{}, error) {
+	switch expr.Literal {
+	case nil:
+		c.emitOp(OpNull)
+	case true:
+		c.emitOp(OpTrue)
+	case false:
+		c.emitOp(OpFalse)
+	default:
+		c.emitConstant(expr.Literal)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitLogicExpr(expr models.LogicExpr) (interface{}, error) {
+	c.compileExpr(expr.Left)
+
+	c.token = expr.Operator
+	if expr.Operator.Type == models.OR {
+		elseJump := c.emitJump(OpJumpIfFalse)
+		endJump := c.emitJump(OpJump)
+		c.patchJump(elseJump)
+		c.emitOp(OpPop)
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+	} else {
+		endJump := c.emitJump(OpJumpIfFalse)
+		c.emitOp(OpPop)
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+	}
+	return nil, nil
+}
+
+// compileCompound applies the operator of a compound
+// assignment to the target's current value and the new
+// value on top of the stack. It does nothing for '='.
+func (c *Compiler) compileCompound(operator *models.Token) {
+	if operator == nil {
+		return
+	}
+
+	c.token = *operator
+	c.emitOp(binaryOps[operator.Type])
+}
+
+func (c *Compiler) VisitVarExpr(expr models.VarExpr) (interface{}, error) {
+	c.token = expr.Name
+	c.namedVariable(expr.Binding.RuntimeName(expr.Name), false)
+	return nil, nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr models.AssignExpr) (interface{}, error) {
+	if expr.Operator != nil {
+		c.token = expr.Name
+		c.namedVariable(expr.Name, false)
+	}
+	c.compileExpr(expr.Value)
+	c.compileCompound(expr.Operator)
+
+	c.token = expr.Name
+	c.namedVariable(expr.Name, true)
+	return nil, nil
+}
+
+func (c *Compiler) VisitCallExpr(expr models.CallExpr) (interface{}, error) {
+	c.compileExpr(expr.Callee)
+	for _, argument := range expr.Arguments {
+		c.compileExpr(argument)
+	}
+
+	c.token = expr.Paren
+	if len(expr.Arguments) > math.MaxUint8 {
+		c.reportError(expr.Paren, fmt.Sprintf("Cannot have more than %d arguments.", math.MaxUint8))
+		return nil, nil
+	}
+	c.emitOp(OpCall)
+	c.emitByte(byte(len(expr.Arguments)))
+	return nil, nil
+}
+
+func (c *Compiler) VisitListExpr(expr models.ListExpr) (interface{}, error) {
+	for _, element := range expr.Elements {
+		c.compileExpr(element)
+	}
+
+	c.token = expr.Bracket
+	if len(expr.Elements) > math.MaxUint16 {
+		c.reportError(expr.Bracket, fmt.Sprintf("Cannot have more than %d elements in a list literal.", math.MaxUint16))
+		return nil, nil
+	}
+	c.emitOp(OpList)
+	c.emitShort(len(expr.Elements))
+	return nil, nil
+}
+
+func (c *Compiler) VisitMapExpr(expr models.MapExpr) (interface{}, error) {
+	for _, entry := range expr.Entries {
+		c.compileExpr(entry.Key)
+		c.compileExpr(entry.Value)
+	}
+
+	c.token = expr.Brace
+	if len(expr.Entries) > math.MaxUint16 {
+		c.reportError(expr.Brace, fmt.Sprintf("Cannot have more than %d entries in a map literal.", math.MaxUint16))
+		return nil, nil
+	}
+	c.emitOp(OpMap)
+	c.emitShort(len(expr.Entries))
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexExpr(expr models.IndexExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+
+	c.token = expr.Bracket
+	c.emitOp(OpGetIndex)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexSetExpr(expr models.IndexSetExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	if expr.Operator != nil {
+		// Keep the list and index for OpSetIndex.
+		c.token = expr.Bracket
+		c.emitOp(OpDupPair)
+		c.emitOp(OpGetIndex)
+	}
+	c.compileExpr(expr.Value)
+	c.compileCompound(expr.Operator)
+
+	c.token = expr.Bracket
+	c.emitOp(OpSetIndex)
+	return nil, nil
+}
+
+func (c *Compiler) VisitStructExpr(expr models.StructExpr) (interface{}, error) {
+	c.token = expr.Name
+	c.namedVariable(expr.Name, false)
+
+	for _, field := range expr.Fields {
+		c.token = field.Name
+		c.emitConstant(field.Name.Lexeme)
+		c.compileExpr(field.Value)
+	}
+
+	c.token = expr.Name
+	if len(expr.Fields) > math.MaxUint8 {
+		c.reportError(expr.Name, fmt.Sprintf("Cannot have more than %d fields in a struct literal.", math.MaxUint8))
+		return nil, nil
+	}
+	c.emitOp(OpStruct)
+	c.emitByte(byte(len(expr.Fields)))
+	return nil, nil
+}
+
+func (c *Compiler) VisitGetExpr(expr models.GetExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+
+	c.token = expr.Name
+	c.emitOp(OpGetField)
+	c.emitShort(c.makeConstant(expr.Name.Lexeme))
+	return nil, nil
+}
+
+func (c *Compiler) VisitSetExpr(expr models.SetExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	if expr.Operator != nil {
+		// Keep the instance for OpSetField.
+		c.token = expr.Name
+		c.emitOp(OpDup)
+		c.emitOp(OpGetField)
+		c.emitShort(c.makeConstant(expr.Name.Lexeme))
+	}
+	c.compileExpr(expr.Value)
+	c.compileCompound(expr.Operator)
+
+	c.token = expr.Name
+	c.emitOp(OpSetField)
+	c.emitShort(c.makeConstant(expr.Name.Lexeme))
+	return nil, nil
+}
+
+// namedVariable emits an instruction that reads the
+// variable name, or assigns to it if assign is set.
+func (c *Compiler) namedVariable(name models.Token, assign bool) {
+	if slot := c.resolveLocal(name.Lexeme); slot != -1 {
+		c.emitOp(pickOp(assign, OpSetLocal, OpGetLocal))
+		c.emitShort(slot)
+		return
+	}
+
+	if index := c.resolveUpvalue(name.Lexeme); index != -1 {
+		c.emitOp(pickOp(assign, OpSetUpvalue, OpGetUpvalue))
+		c.emitShort(index)
+		return
+	}
+
+	c.emitOp(pickOp(assign, OpSetGlobal, OpGetGlobal))
+	c.emitShort(c.makeConstant(name.Lexeme))
+}
+
+func pickOp(assign bool, setOp OpCode, getOp OpCode) OpCode {
+	if assign {
+		return setOp
+	}
+	return getOp
+}
+
+// resolveLocal returns the slot of the local
+// variable name, or -1 if there isn't one.
+func (c *Compiler) resolveLocal(name string) int {
+	for slot := len(c.locals) - 1; slot > 0; slot-- {
+		if c.locals[slot].name == name {
+			return slot
+		}
+	}
+	return -1
+}
+
+// resolveUpvalue returns the index of the upvalue that
+// captures name from an enclosing function, adding one
+// if needed, or -1 if name isn't a local of any of them.
+func (c *Compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+
+	if slot := c.enclosing.resolveLocal(name); slot != -1 {
+		c.enclosing.locals[slot].captured = true
+		return c.addUpvalue(slot, true)
+	}
+
+	if index := c.enclosing.resolveUpvalue(name); index != -1 {
+		return c.addUpvalue(index, false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) addUpvalue(index int, isLocal bool) int {
+	for i, upvalue := range c.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(c.upvalues) == maxUpvalues {
+		c.reportTooMany("Too many closure variables in function.")
+		return 0
+	}
+
+	c.upvalues = append(c.upvalues, upvalue{index: index, isLocal: isLocal})
+	c.function.UpvalueCount = len(c.upvalues)
+	return len(c.upvalues) - 1
+}
+
+// defineVariable binds name to the value on top of the stack.
+func (c *Compiler) defineVariable(name models.Token) {
+	if c.scopeDepth == 0 {
+		c.emitOp(OpDefineGlobal)
+		c.emitShort(c.makeConstant(name.Lexeme))
+		return
+	}
+
+	c.addLocal(name.Lexeme)
+}
+
+func (c *Compiler) addLocal(name string) {
+	if len(c.locals) == maxLocals {
+		c.reportTooMany("Too many local variables in function.")
+		return
+	}
+	c.locals = append(c.locals, local{name: name, depth: c.scopeDepth})
+}
+
+// reportTooMany reports that the function has gone past one
+// of the VM's limits, unless it has been reported already.
+func (c *Compiler) reportTooMany(message string) {
+	if !c.tooMany {
+		c.tooMany = true
+		c.reportError(c.token, message)
+	}
+}
+
+func (c *Compiler) beginScope() {
+	c.scopeDepth++
+}
+
+// discardLocals pops the locals of the scopes deeper than
+// depth off the stack, without ending those scopes, for
+// jumps out of them.
+func (c *Compiler) discardLocals(depth int) {
+	for i := len(c.locals) - 1; i > 0 && c.locals[i].depth > depth; i-- {
+		if c.locals[i].captured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+	}
+}
+
+func (c *Compiler) endScope() {
+	c.scopeDepth--
+	for len(c.locals) > 1 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
+		if c.locals[len(c.locals)-1].captured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.function.Chunk.write(b, c.token)
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitShort(value int) {
+	c.emitByte(byte(value >> 8))
+	c.emitByte(byte(value))
+}
+
+func (c *Compiler) emitReturn() {
+	c.emitOp(OpNull)
+	c.emitOp(OpReturn)
+}
+
+func (c *Compiler) emitConstant(value interface{}) {
+	c.emitOp(OpConstant)
+	c.emitShort(c.makeConstant(value))
+}
+
+func (c *Compiler) makeConstant(value interface{}) int {
+	constant := c.function.Chunk.addConstant(value)
+	if constant > math.MaxUint16 {
+		c.reportError(c.token, "Too many constants in one chunk.")
+		return 0
+	}
+	return constant
+}
+
+// emitJump emits op with a placeholder offset
+// and returns where the offset is to patch it.
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitOp(op)
+	c.emitShort(0xffff)
+	return len(c.function.Chunk.Code) - 2
+}
+
+// patchJump makes the jump at offset land
+// on the next instruction emitted.
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.function.Chunk.Code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.reportError(c.token, "Too much code to jump over.")
+	}
+
+	c.function.Chunk.Code[offset] = byte(jump >> 8)
+	c.function.Chunk.Code[offset+1] = byte(jump)
+}
+
+// emitLoop emits a jump back to loopStart.
+func (c *Compiler) emitLoop(loopStart int) {
+	c.emitOp(OpLoop)
+
+	offset := len(c.function.Chunk.Code) - loopStart + 2
+	if offset > math.MaxUint16 {
+		c.reportError(c.token, "Loop body too large.")
+	}
+	c.emitShort(offset)
+}
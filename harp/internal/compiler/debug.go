@@ -0,0 +1,67 @@
+package compiler
+
+import "fmt"
+
+// PrintChunk prints the instructions of function and
+// of every function in its constant pool.
+func PrintChunk(function *Function) {
+	fmt.Printf("== %s ==\n", function.Name)
+	chunk := function.Chunk
+	for offset := 0; offset < len(chunk.Code); {
+		offset = printInstruction(chunk, offset)
+	}
+
+	for _, constant := range chunk.Constants {
+		if nested, ok := constant.(*Function); ok {
+			PrintChunk(nested)
+		}
+	}
+}
+
+// printInstruction prints the instruction at offset
+// and returns the offset of the next one.
+func printInstruction(chunk *Chunk, offset int) int {
+	fmt.Printf("%04d %4d ", offset, chunk.SpanAt(offset).Start.Line)
+
+	op := OpCode(chunk.Code[offset])
+	name := OpCodeNames[op]
+	switch op {
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetField, OpSetField:
+		constant := readShort(chunk, offset+1)
+		fmt.Printf("%-16s %4d '%v'\n", name, constant, chunk.Constants[constant])
+		return offset + 3
+	case OpCall, OpStruct, OpIterate:
+		fmt.Printf("%-16s %4d\n", name, chunk.Code[offset+1])
+		return offset + 2
+	case OpClosure:
+		constant := readShort(chunk, offset+1)
+		function := chunk.Constants[constant].(*Function)
+		fmt.Printf("%-16s %4d %v\n", name, constant, function)
+		offset += 3
+		for i := 0; i < function.UpvalueCount; i++ {
+			kind := "upvalue"
+			if chunk.Code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Printf("%04d    |                     %s %d\n", offset, kind, readShort(chunk, offset+1))
+			offset += 3
+		}
+		return offset
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpList, OpMap:
+		fmt.Printf("%-16s %4d\n", name, readShort(chunk, offset+1))
+		return offset + 3
+	case OpJump, OpJumpIfFalse, OpNext:
+		fmt.Printf("%-16s %4d -> %d\n", name, offset, offset+3+readShort(chunk, offset+1))
+		return offset + 3
+	case OpLoop:
+		fmt.Printf("%-16s %4d -> %d\n", name, offset, offset+3-readShort(chunk, offset+1))
+		return offset + 3
+	}
+
+	fmt.Println(name)
+	return offset + 1
+}
+
+func readShort(chunk *Chunk, offset int) int {
+	return int(chunk.Code[offset])<<8 | int(chunk.Code[offset+1])
+}
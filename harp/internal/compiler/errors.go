@@ -0,0 +1,38 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+)
+
+func (c *Compiler) reportError(token models.Token, message string) {
+	*c.compileErrors = append(*c.compileErrors, &CompileError{
+		Line:    token.Line,
+		Column:  token.Column,
+		Length:  token.Span().Width(),
+		Message: message,
+	})
+}
+
+type CompileError struct {
+	Line    int
+	Column  int
+	Length  int
+	Message string
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("[Line %d:%d] Error: %s", e.Line, e.Column, e.Message)
+}
+
+func (e *CompileError) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Line,
+		Column:   e.Column,
+		Length:   e.Length,
+		Message:  e.Message,
+	}
+}
@@ -0,0 +1,116 @@
+// Package diagnostics collects the errors and warnings
+// found while running a program and renders them
+// against the source they point into.
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "Warning"
+	}
+	return "Error"
+}
+
+// Diagnostic is an error or warning at a place in the
+// source. Line 0 means it has no known position.
+type Diagnostic struct {
+	Severity Severity
+	Line     int
+	Column   int
+	// Length is how many columns to underline, at least 1.
+	Length  int
+	Message string
+	Notes   []Note
+	// Help holds suggestions for fixing the problem.
+	Help []string
+}
+
+// Note adds context to a diagnostic, such as where
+// something it mentions was declared. Line 0 means
+// the note has no position of its own.
+type Note struct {
+	Line    int
+	Column  int
+	Length  int
+	Message string
+}
+
+// Diagnoser is implemented by the errors of each stage
+// that can describe themselves as a Diagnostic.
+type Diagnoser interface {
+	Diagnostic() *Diagnostic
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("[Line %d:%d] %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+// From returns err as a Diagnostic. Errors that don't
+// implement Diagnoser become errors with no position.
+func From(err error) *Diagnostic {
+	var diagnostic *Diagnostic
+	if errors.As(err, &diagnostic) {
+		return diagnostic
+	}
+
+	var diagnoser Diagnoser
+	if errors.As(err, &diagnoser) {
+		return diagnoser.Diagnostic()
+	}
+
+	return &Diagnostic{Severity: Error, Message: err.Error()}
+}
+
+// IsWarning reports whether err is only a warning,
+// which shouldn't stop a program from running.
+func IsWarning(err error) bool {
+	return From(err).Severity == Warning
+}
+
+// List collects diagnostics from every stage
+// of running a program, in the order found.
+type List struct {
+	diagnostics []*Diagnostic
+}
+
+// Add adds errs to the list.
+func (l *List) Add(errs ...error) {
+	for _, err := range errs {
+		l.diagnostics = append(l.diagnostics, From(err))
+	}
+}
+
+// HasErrors reports whether the list holds
+// anything more serious than a warning.
+func (l *List) HasErrors() bool {
+	for _, diagnostic := range l.diagnostics {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostics returns the diagnostics in the list.
+func (l *List) Diagnostics() []*Diagnostic {
+	return l.diagnostics
+}
+
+// Clear empties the list.
+func (l *List) Clear() {
+	l.diagnostics = nil
+}
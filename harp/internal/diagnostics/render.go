@@ -0,0 +1,147 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	reset  = "\033[0m"
+	bold   = "\033[1m"
+	red    = "\033[1;31m"
+	yellow = "\033[1;33m"
+	blue   = "\033[1;34m"
+	cyan   = "\033[1;36m"
+	green  = "\033[1;32m"
+)
+
+// Renderer prints diagnostics with the lines of
+// source they point at, in color on a terminal.
+type Renderer struct {
+	out   io.Writer
+	path  string
+	lines []string
+	color bool
+}
+
+// NewRenderer returns a Renderer that writes to out about
+// source, which was read from path. Color is used if out
+// is a terminal and the NO_COLOR variable isn't set.
+func NewRenderer(out io.Writer, path string, source string) *Renderer {
+	return &Renderer{
+		out:   out,
+		path:  path,
+		lines: strings.Split(source, "\n"),
+		color: isTerminal(out),
+	}
+}
+
+func isTerminal(out io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// RenderAll renders every diagnostic in list.
+func (r *Renderer) RenderAll(list *List) {
+	for _, diagnostic := range list.Diagnostics() {
+		r.Render(diagnostic)
+	}
+}
+
+// Render prints diagnostic, then its notes and help.
+func (r *Renderer) Render(diagnostic *Diagnostic) {
+	severityColor := red
+	if diagnostic.Severity == Warning {
+		severityColor = yellow
+	}
+
+	width := r.gutterWidth(diagnostic)
+	r.header(strings.ToLower(diagnostic.Severity.String()), severityColor, diagnostic.Message)
+	r.snippet(diagnostic.Line, diagnostic.Column, diagnostic.Length, severityColor, width)
+
+	for _, note := range diagnostic.Notes {
+		if note.Line == 0 {
+			r.footer("note", note.Message, width)
+			continue
+		}
+		r.header("note", cyan, note.Message)
+		r.snippet(note.Line, note.Column, note.Length, cyan, width)
+	}
+	for _, help := range diagnostic.Help {
+		r.footer("help", help, width)
+	}
+
+	fmt.Fprintln(r.out)
+}
+
+// header prints a line like "error: message".
+func (r *Renderer) header(label string, labelColor string, message string) {
+	fmt.Fprintf(r.out, "%s: %s\n", r.paint(label, labelColor), r.paint(message, bold))
+}
+
+// footer prints a line like "  = help: message"
+// under the snippets of a diagnostic.
+func (r *Renderer) footer(label string, message string, width int) {
+	fmt.Fprintf(r.out, "%s %s %s: %s\n", strings.Repeat(" ", width), r.paint("=", blue), r.paint(label, bold), message)
+}
+
+// snippet prints where line and column are, then the
+// source line with length columns underlined.
+func (r *Renderer) snippet(line int, column int, length int, underlineColor string, width int) {
+	if line == 0 {
+		return
+	}
+
+	fmt.Fprintf(r.out, "%s%s %s:%d:%d\n", strings.Repeat(" ", width), r.paint("-->", blue), r.path, line, column)
+	if line > len(r.lines) {
+		return
+	}
+
+	source := []rune(strings.TrimRight(r.lines[line-1], "\r"))
+	column = max(1, min(column, len(source)+1))
+	length = max(1, min(length, len(source)+1-column))
+
+	// Copy tabs from the source so the underline
+	// lines up however wide the terminal draws them.
+	var indent strings.Builder
+	for _, char := range source[:column-1] {
+		if char == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	gutter := r.paint("|", blue)
+	blank := strings.Repeat(" ", width)
+	fmt.Fprintf(r.out, "%s %s\n", blank, gutter)
+	fmt.Fprintf(r.out, "%s %s %s\n", r.paint(fmt.Sprintf("%*d", width, line), blue), gutter, string(source))
+	fmt.Fprintf(r.out, "%s %s %s%s\n", blank, gutter, indent.String(), r.paint(strings.Repeat("^", length), underlineColor))
+}
+
+// gutterWidth returns how many digits the line
+// numbers in diagnostic's snippets need.
+func (r *Renderer) gutterWidth(diagnostic *Diagnostic) int {
+	widest := diagnostic.Line
+	for _, note := range diagnostic.Notes {
+		widest = max(widest, note.Line)
+	}
+	return len(fmt.Sprint(widest))
+}
+
+func (r *Renderer) paint(text string, color string) string {
+	if !r.color {
+		return text
+	}
+	return color + text + reset
+}
@@ -0,0 +1,219 @@
+package diagnostics_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const renderSource = `func add(int s, int b) int {
+	return s + b;
+}
+
+int total = add(1, "2");
+print(totl);
+
+
+
+
+	string  s = "ñé" + 1;`
+
+// renderTests are diagnostics about renderSource, each
+// rendered against the golden file testdata/<name>.golden
+// with the spaces in name replaced by underscores.
+var renderTests = []struct {
+	name       string
+	diagnostic *diagnostics.Diagnostic
+}{
+	{
+		name: "error",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Error,
+			Line:     5,
+			Column:   20,
+			Length:   3,
+			Message:  "Cannot use string as argument 2 of type int.",
+		},
+	},
+	{
+		name: "help",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Error,
+			Line:     6,
+			Column:   7,
+			Length:   4,
+			Message:  "Undefined variable 'totl'.",
+			Help:     []string{"Did you mean 'total'?"},
+		},
+	},
+	{
+		name: "warning with a note",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Warning,
+			Line:     11,
+			Column:   10,
+			Length:   1,
+			Message:  "'s' shadows a parameter.",
+			Notes:    []diagnostics.Note{{Line: 1, Column: 14, Length: 1, Message: "'s' is declared here."}},
+			Help:     []string{"Rename it if it isn't meant to hide the outer 's'."},
+		},
+	},
+	{
+		name: "note without a position",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Error,
+			Line:     2,
+			Column:   9,
+			Length:   5,
+			Message:  "Integer overflow in 9223372036854775807 + 1.",
+			Notes: []diagnostics.Note{
+				{Line: 5, Column: 13, Length: 3, Message: "in add(), called here."},
+				{Message: "... 3 calls omitted."},
+			},
+		},
+	},
+	{
+		name: "tabs and multi-byte characters",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Error,
+			Line:     11,
+			Column:   14,
+			Length:   8,
+			Message:  "Invalid operands to '+': string and int.",
+		},
+	},
+	{
+		name: "past the end of the line",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Error,
+			Line:     3,
+			Column:   4,
+			Length:   3,
+			Message:  "Expect ';' after expression.",
+		},
+	},
+	{
+		name: "no position",
+		diagnostic: &diagnostics.Diagnostic{
+			Severity: diagnostics.Error,
+			Message:  "Maximum call depth of 1000 exceeded.",
+		},
+	},
+}
+
+func TestRender(t *testing.T) {
+	for _, test := range renderTests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			diagnostics.NewRenderer(&out, "test.harp", renderSource).Render(test.diagnostic)
+
+			golden := filepath.Join("testdata", strings.ReplaceAll(test.name, " ", "_")+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("rendered differs from %s\ngot:\n%s\nwant:\n%s", golden, out.String(), want)
+			}
+		})
+	}
+}
+
+func TestRenderAll(t *testing.T) {
+	var list diagnostics.List
+	list.Add(
+		&diagnostics.Diagnostic{Severity: diagnostics.Warning, Line: 6, Column: 7, Length: 4, Message: "first"},
+		errors.New("second"),
+	)
+
+	var out bytes.Buffer
+	diagnostics.NewRenderer(&out, "test.harp", renderSource).RenderAll(&list)
+
+	want := `warning: first
+ --> test.harp:6:7
+  |
+6 | print(totl);
+  |       ^^^^
+
+error: second
+
+`
+	if out.String() != want {
+		t.Errorf("rendered:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"total", "print", "add", "ab", "counter"}
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"totl", "total", true},
+		{"prnt", "print", true},
+		{"ad", "", false},
+		{"pritn", "", false},
+		{"cuonter", "counter", true},
+		{"total", "", false},
+		{"x", "", false},
+		{"zzzzz", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := diagnostics.Suggest(test.name, candidates)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Suggest(%q) = %q, %t, want %q, %t", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// diagnosed is an error from a stage that can
+// describe itself as a Diagnostic.
+type diagnosed struct {
+	severity diagnostics.Severity
+}
+
+func (d diagnosed) Error() string {
+	return "diagnosed"
+}
+
+func (d diagnosed) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{Severity: d.severity, Message: "diagnosed"}
+}
+
+func TestIsWarning(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"warning", &diagnostics.Diagnostic{Severity: diagnostics.Warning}, true},
+		{"error", &diagnostics.Diagnostic{Severity: diagnostics.Error}, false},
+		{"diagnoser warning", diagnosed{diagnostics.Warning}, true},
+		{"diagnoser error", diagnosed{diagnostics.Error}, false},
+		{"wrapped warning", errors.Join(diagnosed{diagnostics.Warning}), true},
+		{"plain error", errors.New("plain"), false},
+	}
+
+	for _, test := range tests {
+		if got := diagnostics.IsWarning(test.err); got != test.want {
+			t.Errorf("IsWarning(%s) = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
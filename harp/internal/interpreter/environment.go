@@ -1,7 +1,20 @@
 package interpreter
 
+import (
+	"fmt"
+	"sort"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+// Environment holds the variables of one scope. The
+// global environment keeps its variables by name, since
+// the REPL and host programs can add globals at any
+// time. Local environments keep theirs in the slots
+// the resolver assigned.
 type Environment struct {
 	values map[string]interface{}
+	slots  []interface{}
 	parent *Environment
 }
 
@@ -9,29 +22,95 @@ func DefineValue(name string, value interface{}, currentEnvironment *Environment
 	currentEnvironment.values[name] = value
 }
 
-func GetValue(name string, currentEnvironment *Environment) interface{} {
+// GetValue looks up name starting at currentEnvironment and
+// reports whether it was found.
+func GetValue(name string, currentEnvironment *Environment) (interface{}, bool) {
 	if val, ok := currentEnvironment.values[name]; ok {
-		return val
+		return val, true
 	}
 
 	if currentEnvironment.parent != nil {
 		return GetValue(name, currentEnvironment.parent)
 	}
 
-	return nil
-
+	return nil, false
 }
 
-func AssignValue(name string, value interface{}, currentEnvironment *Environment) {
+// AssignValue sets an existing variable and reports
+// whether it was found.
+func AssignValue(name string, value interface{}, currentEnvironment *Environment) bool {
 	if _, ok := currentEnvironment.values[name]; ok {
 		currentEnvironment.values[name] = value
-		return
+		return true
 	}
 
 	if currentEnvironment.parent != nil {
-		AssignValue(name, value, currentEnvironment.parent)
-		return
+		return AssignValue(name, value, currentEnvironment.parent)
+	}
+
+	return false
+}
+
+// DefineSlot sets slot in currentEnvironment,
+// growing its slots if needed.
+func DefineSlot(slot int, value interface{}, currentEnvironment *Environment) {
+	for len(currentEnvironment.slots) <= slot {
+		currentEnvironment.slots = append(currentEnvironment.slots, nil)
+	}
+	currentEnvironment.slots[slot] = value
+}
+
+// GetSlot returns the value in slot of the
+// environment depth scopes out from currentEnvironment.
+// A slot not defined yet, such as a local function's
+// before its declaration runs, holds null.
+func GetSlot(depth int, slot int, currentEnvironment *Environment) interface{} {
+	environment := ancestor(depth, currentEnvironment)
+	if slot >= len(environment.slots) {
+		return nil
 	}
+	return environment.slots[slot]
+}
 
-	panic("Undefined variable.")
+// AssignSlot sets slot of the environment depth
+// scopes out from currentEnvironment.
+func AssignSlot(depth int, slot int, value interface{}, currentEnvironment *Environment) {
+	DefineSlot(slot, value, ancestor(depth, currentEnvironment))
+}
+
+func ancestor(depth int, currentEnvironment *Environment) *Environment {
+	for ; depth > 0; depth-- {
+		currentEnvironment = currentEnvironment.parent
+	}
+	return currentEnvironment
+}
+
+// DefineGlobal defines name in the global environment.
+func (i *Interpreter) DefineGlobal(name string, value interface{}) {
+	DefineValue(name, value, i.globals)
+}
+
+// GetGlobal looks up name in the global environment
+// and reports whether it was found.
+func (i *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	return GetValue(name, i.globals)
+}
+
+// PrintGlobals prints every global variable
+// and its value, skipping builtin functions.
+func (i *Interpreter) PrintGlobals() {
+	var names []string
+	for name, value := range i.globals.values {
+		if _, ok := value.(models.Callable); ok {
+			if _, ok := value.(*Function); !ok {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("%s = %s\n", name, models.Format(i.globals.values[name]))
+	}
 }
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+type StackFrame struct {
+	Function string
+	Call     models.Token
+}
+
+type RuntimeError struct {
+	Token   models.Token
+	Message string
+	// Stack is the call stack when the error happened,
+	// innermost call first.
+	Stack []StackFrame
+	// Omitted is how many calls were trimmed from
+	// the middle of Stack, after the first
+	// models.TraceHead.
+	Omitted int
+}
+
+func (e *RuntimeError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[Line %d:%d] Error: %s", e.Token.Line, e.Token.Column, e.Message))
+	for i, frame := range e.Stack {
+		if i == models.TraceHead && e.Omitted > 0 {
+			sb.WriteString("\n    " + models.OmittedCalls(e.Omitted))
+		}
+		sb.WriteString(fmt.Sprintf("\n    in %s() called at [Line %d:%d]", frame.Function, frame.Call.Line, frame.Call.Column))
+	}
+
+	return sb.String()
+}
+
+// Diagnostic describes the error, with a note
+// at each call on the stack that led to it.
+func (e *RuntimeError) Diagnostic() *diagnostics.Diagnostic {
+	diagnostic := &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Token.Line,
+		Column:   e.Token.Column,
+		Length:   e.Token.Span().Width(),
+		Message:  e.Message,
+	}
+	for i, frame := range e.Stack {
+		if i == models.TraceHead && e.Omitted > 0 {
+			diagnostic.Notes = append(diagnostic.Notes, diagnostics.Note{Message: models.OmittedCalls(e.Omitted) + "."})
+		}
+		diagnostic.Notes = append(diagnostic.Notes, diagnostics.Note{
+			Line:    frame.Call.Line,
+			Column:  frame.Call.Column,
+			Length:  frame.Call.Span().Width(),
+			Message: fmt.Sprintf("in %s(), called here.", frame.Function),
+		})
+	}
+
+	return diagnostic
+}
+
+func (i *Interpreter) newRuntimeError(token models.Token, message string) *RuntimeError {
+	stack := make([]StackFrame, len(i.callStack))
+	for depth, frame := range i.callStack {
+		stack[len(i.callStack)-1-depth] = frame
+	}
+
+	stack, omitted := models.TrimTrace(stack)
+	return &RuntimeError{Token: token, Message: message, Stack: stack, Omitted: omitted}
+}
+
+func (i *Interpreter) pushFrame(function string, call models.Token) {
+	i.callStack = append(i.callStack, StackFrame{Function: function, Call: call})
+}
+
+func (i *Interpreter) popFrame() {
+	i.callStack = i.callStack[:len(i.callStack)-1]
+}
@@ -0,0 +1,105 @@
+package interpreter_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/interpreter"
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/resolver"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+// TestRuntimeErrorDiagnostic checks that a runtime error
+// in nested calls underlines where it happened and adds a
+// note at each call on the stack, innermost first.
+func TestRuntimeErrorDiagnostic(t *testing.T) {
+	source := `func at(list<int> xs, int i) int {
+    return xs[i];
+}
+func second(list<int> xs) int {
+    return at(xs, 1);
+}
+print(second([1]));`
+
+	err := run(t, source)
+	var runtimeError *interpreter.RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Fatalf("got %v, want a *RuntimeError", err)
+	}
+
+	want := "[Line 2:14] Error: Index 1 out of range for list of length 1.\n" +
+		"    in at() called at [Line 5:20]\n" +
+		"    in second() called at [Line 7:17]"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+
+	diagnostic := diagnostics.From(err)
+	if diagnostic.Line != 2 || diagnostic.Column != 14 || diagnostic.Length != 1 {
+		t.Errorf("diagnostic at %d:%d with length %d, want 2:14 with length 1", diagnostic.Line, diagnostic.Column, diagnostic.Length)
+	}
+	wantNotes := []diagnostics.Note{
+		{Line: 5, Column: 20, Length: 1, Message: "in at(), called here."},
+		{Line: 7, Column: 17, Length: 1, Message: "in second(), called here."},
+	}
+	if len(diagnostic.Notes) != len(wantNotes) {
+		t.Fatalf("got %d notes, want %d: %v", len(diagnostic.Notes), len(wantNotes), diagnostic.Notes)
+	}
+	for i, note := range diagnostic.Notes {
+		if note != wantNotes[i] {
+			t.Errorf("note %d = %+v, want %+v", i, note, wantNotes[i])
+		}
+	}
+}
+
+// TestTrimmedTraceDiagnostic checks that the calls left out
+// of a deep trace get a note without a position.
+func TestTrimmedTraceDiagnostic(t *testing.T) {
+	source := `func down(int n) int {
+    if (n == 0) {
+        return 1 / n;
+    }
+    return down(n - 1);
+}
+down(30);`
+
+	diagnostic := diagnostics.From(run(t, source))
+	var omitted []string
+	for _, note := range diagnostic.Notes {
+		if note.Line == 0 {
+			omitted = append(omitted, note.Message)
+		}
+	}
+	if len(omitted) != 1 || omitted[0] != "... 23 more calls." {
+		t.Errorf("notes without a position = %q, want [\"... 23 more calls.\"]", omitted)
+	}
+}
+
+// run checks source, which must be free of mistakes,
+// and returns the error from interpreting it.
+func run(t *testing.T, source string) error {
+	t.Helper()
+
+	tokens, errs := scanner.Scan(source)
+	if len(errs) > 0 {
+		t.Fatalf("scan: %v", errs)
+	}
+	stmts, errs := parser.Parse(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse: %v", errs)
+	}
+	if errs := checker.Check(stmts); len(errs) > 0 {
+		t.Fatalf("check: %v", errs)
+	}
+	if errs := resolver.Resolve(stmts); len(errs) > 0 {
+		t.Fatalf("resolve: %v", errs)
+	}
+
+	runner := interpreter.NewInterpreter()
+	runner.SetOutput(io.Discard)
+	return runner.Interpret(stmts)
+}
@@ -1,306 +1,710 @@
 package interpreter
 
 import (
-	"math"
-	"reflect"
-	"strconv"
+	"fmt"
+	"io"
+	"os"
 
 	"github.com/astraikis/harp/internal/models"
 )
 
-var Globals = &Environment{values: map[string]interface{}{}, parent: nil}
-var currEnvironment = Globals
-
+// Interpreter runs a syntax tree. Each Interpreter
+// holds its own state, so separate Interpreters can
+// run at the same time.
 type Interpreter struct {
-	intGlobals *Environment
-	intCurr    *Environment
+	globals         *Environment
+	currEnvironment *Environment
+	// callStack holds a frame for every Harp
+	// function call that hasn't returned yet.
+	callStack []StackFrame
+	// maxDepth is how many Harp function
+	// calls callStack can hold.
+	maxDepth int
+	// nesting is how many statements and expressions
+	// are being run inside each other, each of which
+	// holds a few frames on the Go stack.
+	nesting int
 }
 
 type Function struct {
 	*models.Function
+	// Closure is the environment the function was
+	// defined in, which its body runs inside.
+	Closure     *Environment
 	Interpreter *Interpreter
+	signature   models.Signature
+}
+
+func (f *Function) Signature() models.Signature {
+	return f.signature
+}
+
+func (f *Function) String() string {
+	return "<func " + f.Name + ">"
+}
+
+// completion is handed back up through the executing
+// statements by a statement that leaves them early. A
+// return goes up to the function call it returns from,
+// and a break or continue to the loop it names.
+type completion struct {
+	// kind is RETURN, BREAK or CONTINUE.
+	kind  models.TokenType
+	value interface{}
+	// label is the label of the loop a break or
+	// continue leaves, or nil for the innermost one.
+	label *models.Token
+}
+
+// leaves reports whether c is a break or continue
+// out of the loop labelled label, if it has one.
+func (c *completion) leaves(label *models.Token) bool {
+	if c.kind == models.RETURN {
+		return false
+	}
+	return c.label == nil || (label != nil && c.label.Lexeme == label.Lexeme)
 }
 
-func (f *Function) Call(arguments []models.Expr) interface{} {
-	env := &Environment{values: map[string]interface{}{}, parent: currEnvironment}
+func (f *Function) Call(arguments []interface{}) (interface{}, error) {
+	env := &Environment{slots: make([]interface{}, 0, len(f.Params)), parent: f.Closure}
 
-	for i, param := range f.Params {
-		DefineValue(param.Name, arguments[i], env)
+	// The resolver puts the parameters in the first slots.
+	for i := range f.Params {
+		DefineSlot(i, arguments[i], env)
 	}
 
-	executeBlockStmt(f.Body, env)
-	return nil
+	result, err := f.Interpreter.executeBlock(f.Body, env)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return result.value, nil
+	}
+	return nil, nil
+}
+
+// NewInterpreter returns an Interpreter whose
+// global environment holds only the builtins.
+func NewInterpreter() *Interpreter {
+	globals := &Environment{values: map[string]interface{}{}, parent: nil}
+	for name, builtin := range models.Builtins(os.Stdout) {
+		DefineValue(name, builtin, globals)
+	}
+
+	return &Interpreter{globals: globals, currEnvironment: globals, maxDepth: models.DefaultMaxDepth}
 }
 
-func Interpret(statements []models.Stmt) {
-	DefineValue("clock", models.Clock{}, currEnvironment)
-	DefineValue("print", models.Print{}, currEnvironment)
+// maxNesting is how deeply statements and expressions can
+// nest, counting through calls, before a call fails with a
+// stack overflow error. The interpreter recurses on the Go
+// stack, and running out of it crashes the whole process,
+// so this keeps well inside Go's default 1 GB limit: the
+// deepest-nesting programs measured use about 1.2 KB of
+// stack per level, so this many levels need under 400 MB.
+const maxNesting = 300000
+
+// SetMaxDepth sets how deeply calls to Harp functions can
+// nest before they fail with a stack overflow error. Calls
+// whose bodies nest deeply can also fail sooner, when the
+// interpreter runs short of stack.
+func (i *Interpreter) SetMaxDepth(depth int) {
+	i.maxDepth = depth
+}
+
+// SetOutput makes print write to out.
+func (i *Interpreter) SetOutput(out io.Writer) {
+	DefineValue("print", models.Print{Out: out}, i.globals)
+}
 
+// Interpret runs statements and returns the
+// runtime error that stopped them, if any.
+func Interpret(statements []models.Stmt) error {
+	return NewInterpreter().Interpret(statements)
+}
+
+// Interpret runs statements, which must have been
+// resolved, and returns the runtime error that stopped
+// them, if any. Globals are kept between calls, so
+// later stmts can use earlier ones.
+func (i *Interpreter) Interpret(statements []models.Stmt) error {
 	for _, stmt := range statements {
-		execute(stmt)
+		if _, err := i.execute(stmt); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
-func execute(stmt models.Stmt) {
-	switch reflect.TypeOf(stmt).String() {
-	case "models.ExprStmt":
-		executeExprStmt(stmt.(models.ExprStmt))
-	case "models.VarStmt":
-		executeVarStmt(stmt.(models.VarStmt))
-	case "models.BlockStmt":
-		executeBlockStmt(stmt.(models.BlockStmt).Statements, &Environment{values: map[string]interface{}{}, parent: currEnvironment})
-	case "models.IfStmt":
-		executeIfStmt(stmt.(models.IfStmt))
-	case "models.WhileStmt":
-		executeWhileStmt(stmt.(models.WhileStmt))
-	case "models.FuncStmt":
-		executeFuncStmt(stmt.(models.FuncStmt))
-	}
+// Evaluate evaluates expr in the global environment
+// and returns its value.
+func (i *Interpreter) Evaluate(expr models.Expr) (interface{}, error) {
+	return i.evaluate(expr)
+}
+
+// execute runs stmt. If stmt hits a return, break or
+// continue statement, its completion is passed back
+// to the caller.
+func (i *Interpreter) execute(stmt models.Stmt) (*completion, error) {
+	i.nesting++
+	result, err := stmt.Accept(i)
+	i.nesting--
+	value, _ := result.(*completion)
+	return value, err
+}
+
+func (i *Interpreter) VisitFuncStmt(stmt models.FuncStmt) (interface{}, error) {
+	i.define(stmt.Name, stmt.Binding, i.newFunction(stmt.Name.Lexeme, stmt.Params, stmt.ReturnType, stmt.Body))
+	return nil, nil
 }
 
-func executeFuncStmt(stmt models.FuncStmt) {
-	//function := models.Function{Name: stmt.Name.Lexeme, Params: stmt.Params, Body: stmt.Body}
-	function := &Function{
+// newFunction returns a function that closes
+// over the current environment.
+func (i *Interpreter) newFunction(name string, params []models.FuncParam, returnType *models.TypeExpr, body []models.Stmt) *Function {
+	return &Function{
 		Function: &models.Function{
-			Name:   stmt.Name.Lexeme,
-			Params: stmt.Params,
-			Body:   stmt.Body,
+			Name:   name,
+			Params: params,
+			Body:   body,
 		},
+		Closure:     i.currEnvironment,
+		Interpreter: i,
+		signature:   models.SignatureOf(params, returnType),
 	}
-	DefineValue(stmt.Name.Lexeme, function, currEnvironment)
 }
 
-func executeExprStmt(stmt models.ExprStmt) {
-	evaluate(stmt.Expression)
+func (i *Interpreter) VisitStructStmt(stmt models.StructStmt) (interface{}, error) {
+	structType := models.StructOf(stmt)
+	i.define(stmt.Name, stmt.Binding, structType)
+	return nil, nil
+}
+
+func (i *Interpreter) VisitExprStmt(stmt models.ExprStmt) (interface{}, error) {
+	_, err := i.evaluate(stmt.Expression)
+	return nil, err
 }
 
-func executeVarStmt(stmt models.VarStmt) {
-	var value models.Expr
+func (i *Interpreter) VisitVarStmt(stmt models.VarStmt) (interface{}, error) {
+	value := models.TypeOf(stmt.Type).Zero()
 	if stmt.Initializer != nil {
-		value = evaluate(stmt.Initializer)
+		initializer, err := i.evaluate(stmt.Initializer)
+		if err != nil {
+			return nil, err
+		}
+		value = initializer
 	}
 
-	DefineValue(stmt.Name.Lexeme, value, currEnvironment)
+	i.define(stmt.Name, stmt.Binding, value)
+	return nil, nil
 }
 
-func executeBlockStmt(blockStmts []models.Stmt, blockEnvironment *Environment) {
-	prevEnvironment := currEnvironment
-	currEnvironment = blockEnvironment
+func (i *Interpreter) VisitReturnStmt(stmt models.ReturnStmt) (interface{}, error) {
+	var value interface{}
+	if stmt.Value != nil {
+		result, err := i.evaluate(stmt.Value)
+		if err != nil {
+			return nil, err
+		}
+		value = result
+	}
+
+	return &completion{kind: models.RETURN, value: value}, nil
+}
+
+func (i *Interpreter) VisitBlockStmt(stmt models.BlockStmt) (interface{}, error) {
+	return i.executeBlock(stmt.Statements, &Environment{parent: i.currEnvironment})
+}
+
+// executeBlock runs blockStmts in blockEnvironment.
+func (i *Interpreter) executeBlock(blockStmts []models.Stmt, blockEnvironment *Environment) (*completion, error) {
+	prevEnvironment := i.currEnvironment
+	i.currEnvironment = blockEnvironment
+	defer func() { i.currEnvironment = prevEnvironment }()
 
 	for _, stmt := range blockStmts {
-		execute(stmt)
+		result, err := i.execute(stmt)
+		if err != nil || result != nil {
+			return result, err
+		}
 	}
 
-	currEnvironment = prevEnvironment
+	return nil, nil
 }
 
-func executeIfStmt(stmt models.IfStmt) {
-	if isTruthy(evaluate(stmt.Condition)) {
-		execute(stmt.ThenBranch)
+func (i *Interpreter) VisitIfStmt(stmt models.IfStmt) (interface{}, error) {
+	condition, err := i.evaluate(stmt.Condition)
+	if err != nil {
+		return nil, err
+	}
+
+	if isTruthy(condition) {
+		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		execute(stmt.ElseBranch)
+		return i.execute(stmt.ElseBranch)
+	}
+
+	return nil, nil
+}
+
+func (i *Interpreter) VisitWhileStmt(stmt models.WhileStmt) (interface{}, error) {
+	for {
+		condition, err := i.evaluate(stmt.Condition)
+		if err != nil {
+			return nil, err
+		}
+		if !isTruthy(condition) {
+			break
+		}
+
+		result, err := i.execute(stmt.Body)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			if !result.leaves(stmt.Label) {
+				return result, nil
+			}
+			if result.kind == models.BREAK {
+				break
+			}
+		}
+
+		if stmt.Increment != nil {
+			if _, err := i.evaluate(stmt.Increment); err != nil {
+				return nil, err
+			}
+		}
 	}
+
+	return nil, nil
 }
 
-func executeWhileStmt(stmt models.WhileStmt) {
+func (i *Interpreter) VisitForEachStmt(stmt models.ForEachStmt) (interface{}, error) {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := models.NewIterator(iterable, len(stmt.Vars))
+	if err != nil {
+		return nil, i.newRuntimeError(stmt.In, err.Error())
+	}
+
 	for {
-		if !isTruthy(evaluate(stmt.Condition)) {
+		values, ok := iterator.Next()
+		if !ok {
 			break
 		}
-		execute(stmt.Body)
+
+		// Each pass gets its own environment, so closures
+		// made in the body keep that pass's variables.
+		env := &Environment{slots: make([]interface{}, 0, len(values)), parent: i.currEnvironment}
+		for slot, value := range values {
+			DefineSlot(slot, value, env)
+		}
+
+		result, err := i.executeBlock([]models.Stmt{stmt.Body}, env)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			if !result.leaves(stmt.Label) {
+				return result, nil
+			}
+			if result.kind == models.BREAK {
+				break
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (i *Interpreter) VisitBreakStmt(stmt models.BreakStmt) (interface{}, error) {
+	return &completion{kind: models.BREAK, label: stmt.Label}, nil
+}
+
+func (i *Interpreter) VisitContinueStmt(stmt models.ContinueStmt) (interface{}, error) {
+	return &completion{kind: models.CONTINUE, label: stmt.Label}, nil
+}
+
+// VisitErrorStmt never runs, since programs
+// with parse errors aren't interpreted.
+func (i *Interpreter) VisitErrorStmt(stmt models.ErrorStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (i *Interpreter) evaluate(expr models.Expr) (interface{}, error) {
+	i.nesting++
+	value, err := expr.Accept(i)
+	i.nesting--
+	return value, err
+}
+
+func (i *Interpreter) VisitFuncExpr(expr models.FuncExpr) (interface{}, error) {
+	return i.newFunction("anonymous", expr.Params, expr.ReturnType, expr.Body), nil
+}
+
+func (i *Interpreter) VisitStructExpr(expr models.StructExpr) (interface{}, error) {
+	value, _ := i.lookUp(expr.Name, expr.Binding)
+	structType, ok := value.(*models.Struct)
+	if !ok {
+		return nil, i.newRuntimeError(expr.Name, fmt.Sprintf("'%s' is not a struct.", expr.Name.Lexeme))
+	}
+
+	instance := models.NewInstance(structType)
+	for _, field := range expr.Fields {
+		if _, ok := instance.Fields[field.Name.Lexeme]; !ok {
+			return nil, i.newRuntimeError(field.Name, fmt.Sprintf("Struct '%s' has no field '%s'.", structType.Name, field.Name.Lexeme))
+		}
+
+		value, err := i.evaluate(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		instance.Fields[field.Name.Lexeme] = value
+	}
+
+	return instance, nil
+}
+
+func (i *Interpreter) VisitGetExpr(expr models.GetExpr) (interface{}, error) {
+	instance, err := i.evaluateInstance(expr.Object, expr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return instance.Fields[expr.Name.Lexeme], nil
+}
+
+func (i *Interpreter) VisitSetExpr(expr models.SetExpr) (interface{}, error) {
+	instance, err := i.evaluateInstance(expr.Object, expr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	value, err = i.applyCompound(expr.Operator, instance.Fields[expr.Name.Lexeme], value)
+	if err != nil {
+		return nil, err
+	}
+
+	instance.Fields[expr.Name.Lexeme] = value
+	return value, nil
+}
+
+// evaluateInstance evaluates the object of a field access
+// and checks that it's a struct with the field name.
+func (i *Interpreter) evaluateInstance(objectExpr models.Expr, name models.Token) (*models.Instance, error) {
+	object, err := i.evaluate(objectExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	if object == nil {
+		return nil, i.newRuntimeError(name, fmt.Sprintf("Cannot access field '%s' of null.", name.Lexeme))
+	}
+	instance, ok := object.(*models.Instance)
+	if !ok {
+		return nil, i.newRuntimeError(name, "Only structs have fields.")
+	}
+	if _, ok := instance.Fields[name.Lexeme]; !ok {
+		return nil, i.newRuntimeError(name, fmt.Sprintf("Struct '%s' has no field '%s'.", instance.Struct.Name, name.Lexeme))
+	}
+
+	return instance, nil
+}
+
+func (i *Interpreter) VisitListExpr(expr models.ListExpr) (interface{}, error) {
+	list := &models.List{Elements: []interface{}{}}
+	for _, element := range expr.Elements {
+		value, err := i.evaluate(element)
+		if err != nil {
+			return nil, err
+		}
+		list.Elements = append(list.Elements, value)
 	}
+
+	return list, nil
 }
 
-func evaluate(expr models.Expr) interface{} {
-	switch reflect.TypeOf(expr).String() {
-	case "models.BinaryExpr":
-		return evaluateBinaryExpr(expr.(models.BinaryExpr))
-	case "models.LiteralExpr":
-		return evaluateLiteralExpr(expr.(models.LiteralExpr))
-	case "models.GroupingExpr":
-		return evaluateGroupingExpr(expr.(models.GroupingExpr))
-	case "models.VarExpr":
-		return evaluateVarExpr(expr.(models.VarExpr))
-	case "models.AssignExpr":
-		return evaluateAssignExpr(expr.(models.AssignExpr))
-	case "models.LogicExpr":
-		return evaluateLogicExpr(expr.(models.LogicExpr))
-	case "models.CallExpr":
-		return evaluateCallExpr(expr.(models.CallExpr))
+func (i *Interpreter) VisitMapExpr(expr models.MapExpr) (interface{}, error) {
+	var values []interface{}
+	for _, entry := range expr.Entries {
+		key, err := i.evaluate(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, key, value)
 	}
 
-	return ""
+	// Keys are checked once every entry is evaluated,
+	// like the VM does.
+	m := models.NewMap()
+	for j := 0; j < len(values); j += 2 {
+		if err := models.CheckKey(values[j]); err != nil {
+			return nil, i.newRuntimeError(expr.Brace, err.Error())
+		}
+		m.Set(values[j], values[j+1])
+	}
+	return m, nil
+}
+
+func (i *Interpreter) VisitIndexExpr(expr models.IndexExpr) (interface{}, error) {
+	object, index, err := i.evaluateIndex(expr.Object, expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := models.GetIndex(object, index)
+	if err != nil {
+		return nil, i.newRuntimeError(expr.Bracket, err.Error())
+	}
+	return value, nil
 }
 
-func evaluateCallExpr(expr models.CallExpr) interface{} {
-	callee := evaluate(expr.Callee)
+func (i *Interpreter) VisitIndexSetExpr(expr models.IndexSetExpr) (interface{}, error) {
+	object, index, err := i.evaluateIndex(expr.Object, expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	// A plain assignment can add a key to a map,
+	// so only read the current value if needed.
+	var current interface{}
+	if expr.Operator != nil {
+		current, err = models.GetIndex(object, index)
+		if err != nil {
+			return nil, i.newRuntimeError(expr.Bracket, err.Error())
+		}
+	}
+
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	value, err = i.applyCompound(expr.Operator, current, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.SetIndex(object, index, value); err != nil {
+		return nil, i.newRuntimeError(expr.Bracket, err.Error())
+	}
+	return value, nil
+}
+
+// evaluateIndex evaluates the list or map
+// and the index of an index expression.
+func (i *Interpreter) evaluateIndex(objectExpr models.Expr, indexExpr models.Expr) (interface{}, interface{}, error) {
+	object, err := i.evaluate(objectExpr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index, err := i.evaluate(indexExpr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return object, index, nil
+}
+
+func (i *Interpreter) VisitCallExpr(expr models.CallExpr) (interface{}, error) {
+	callee, err := i.evaluate(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
+
+	var arguments []interface{}
+	for _, argumentExpr := range expr.Arguments {
+		argument, err := i.evaluate(argumentExpr)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+	}
+
+	function, ok := callee.(models.Callable)
+	if !ok {
+		return nil, i.newRuntimeError(expr.Paren, "Can only call functions.")
+	}
+	if err := function.Signature().CheckArguments(arguments); err != nil {
+		return nil, i.newRuntimeError(expr.Paren, err.Error())
+	}
 
-	var arguments []models.Expr
-	for i := 0; i < len(expr.Arguments); i++ {
-		arguments = append(arguments, evaluate(expr.Arguments[i]))
+	harpFunction, ok := function.(*Function)
+	if !ok {
+		result, err := function.Call(arguments)
+		if err != nil {
+			return nil, i.newRuntimeError(expr.Paren, err.Error())
+		}
+		return result, nil
 	}
 
-	function := callee.(models.Callable)
-	if len(arguments) != function.Arity() {
-		// Error
+	if len(i.callStack) == i.maxDepth {
+		return nil, i.newRuntimeError(expr.Paren, models.StackOverflow(i.maxDepth))
+	}
+	// Only calls can nest without end, so
+	// checking here keeps the Go stack safe.
+	if i.nesting > maxNesting {
+		return nil, i.newRuntimeError(expr.Paren, "Stack overflow: calls and the code inside them nest too deeply.")
 	}
+	i.pushFrame(harpFunction.Name, expr.Paren)
+	defer i.popFrame()
 
 	return function.Call(arguments)
 }
 
-func evaluateLogicExpr(expr models.LogicExpr) interface{} {
-	left := evaluate(expr.Left)
+func (i *Interpreter) VisitLogicExpr(expr models.LogicExpr) (interface{}, error) {
+	left, err := i.evaluate(expr.Left)
+	if err != nil {
+		return nil, err
+	}
 
 	if expr.Operator.Type == models.OR {
 		if isTruthy(left) {
-			return left
+			return left, nil
 		}
 	} else {
 		if !isTruthy(left) {
-			return left
+			return left, nil
 		}
 	}
 
-	return evaluate(expr.Right)
+	return i.evaluate(expr.Right)
+}
+
+// applyCompound returns the value a compound assignment
+// with operator stores in a target holding current, or
+// value itself if operator is nil.
+func (i *Interpreter) applyCompound(operator *models.Token, current interface{}, value interface{}) (interface{}, error) {
+	if operator == nil {
+		return value, nil
+	}
+
+	result, err := models.Binary(operator.Type, current, value)
+	if err != nil {
+		return nil, i.newRuntimeError(*operator, err.Error())
+	}
+	return result, nil
 }
 
-func evaluateBinaryExpr(expr models.BinaryExpr) interface{} {
-	left := evaluate(expr.Left)
-	right := evaluate(expr.Right)
+func (i *Interpreter) VisitBinaryExpr(expr models.BinaryExpr) (interface{}, error) {
+	left, err := i.evaluate(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := i.evaluate(expr.Right)
+	if err != nil {
+		return nil, err
+	}
 
 	switch expr.Operator.Type {
 	case models.EQUAL_EQUAL:
-		return left == right
+		return left == right, nil
 	case models.BANG_EQUAL:
-		return left != right
+		return left != right, nil
 	}
 
-	if isFloat(left) || isFloat(right) {
-		// Evaluate as float.
-		leftFloat := 0.0
-		rightFloat := 0.0
+	result, err := models.Binary(expr.Operator.Type, left, right)
+	if err != nil {
+		return nil, i.newRuntimeError(expr.Operator, err.Error())
+	}
+	return result, nil
+}
 
-		if isString(left) {
-			leftParsed, err := strconv.ParseFloat(left.(string), 64)
-			if err != nil {
-				panic(err)
-			}
-			leftFloat = leftParsed
-		} else {
-			leftFloat = left.(float64)
-		}
-		if isString(right) {
-			rightParsed, err := strconv.ParseFloat(right.(string), 64)
-			if err != nil {
-				panic(err)
-			}
-			rightFloat = rightParsed
-		} else {
-			rightFloat = right.(float64)
-		}
+func (i *Interpreter) VisitGroupingExpr(expr models.GroupingExpr) (interface{}, error) {
+	return i.evaluate(expr.Expression)
+}
 
-		switch expr.Operator.Type {
-		case models.PLUS:
-			return leftFloat + rightFloat
-		case models.MINUS:
-			return leftFloat - rightFloat
-		case models.STAR:
-			return leftFloat * rightFloat
-		case models.SLASH:
-			return leftFloat / rightFloat
-		case models.LESS:
-			return leftFloat < rightFloat
-		case models.LESS_EQUAL:
-			return leftFloat <= rightFloat
-		case models.GREATER:
-			return leftFloat > rightFloat
-		case models.GREATER_EQUAL:
-			return leftFloat >= rightFloat
-		}
-	} else {
-		// Evaluate as int.
-		leftInt := 0
-		rightInt := 0
-
-		if isString(left) {
-			leftParsed, err := strconv.Atoi(left.(string))
-			if err != nil {
-				panic(err)
-			}
-			leftInt = leftParsed
-		} else {
-			leftInt = left.(int)
-		}
-		if isString(right) {
-			rightParsed, err := strconv.Atoi(right.(string))
-			if err != nil {
-				panic(err)
-			}
-			rightInt = rightParsed
-		} else {
-			rightInt = right.(int)
-		}
+func (i *Interpreter) VisitLiteralExpr(expr models.LiteralExpr) (interface{}, error) {
+	return expr.Literal, nil
+}
 
-		switch expr.Operator.Type {
-		case models.PLUS:
-			return leftInt + rightInt
-		case models.MINUS:
-			return leftInt - rightInt
-		case models.STAR:
-			return leftInt * rightInt
-		case models.SLASH:
-			return leftInt / rightInt
-		case models.LESS:
-			return leftInt < rightInt
-		case models.LESS_EQUAL:
-			return leftInt <= rightInt
-		case models.GREATER:
-			return leftInt > rightInt
-		case models.GREATER_EQUAL:
-			return leftInt >= rightInt
+func (i *Interpreter) VisitUnaryExpr(expr models.UnaryExpr) (interface{}, error) {
+	right, err := i.evaluate(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expr.Operator.Type {
+	case models.BANG:
+		return !isTruthy(right), nil
+	case models.MINUS:
+		value, err := models.Negate(right)
+		if err != nil {
+			return nil, i.newRuntimeError(expr.Operator, err.Error())
 		}
+		return value, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
-func evaluateGroupingExpr(expr models.GroupingExpr) interface{} {
-	return evaluate(expr.Expression)
+// VisitErrorExpr never runs, since programs
+// with parse errors aren't interpreted.
+func (i *Interpreter) VisitErrorExpr(expr models.ErrorExpr) (interface{}, error) {
+	return nil, nil
 }
 
-func evaluateLiteralExpr(expr models.LiteralExpr) interface{} {
-	return expr.Literal
+func (i *Interpreter) VisitVarExpr(expr models.VarExpr) (interface{}, error) {
+	return i.lookUp(expr.Name, expr.Binding)
 }
 
-func evaluateVarExpr(expr models.VarExpr) interface{} {
-	return GetValue(expr.Name.Lexeme, currEnvironment)
-}
+func (i *Interpreter) VisitAssignExpr(expr models.AssignExpr) (interface{}, error) {
+	var current interface{}
+	if expr.Operator != nil {
+		var err error
+		current, err = i.lookUp(expr.Name, expr.Binding)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	value, err = i.applyCompound(expr.Operator, current, value)
+	if err != nil {
+		return nil, err
+	}
 
-func evaluateAssignExpr(expr models.AssignExpr) interface{} {
-	value := evaluate(expr.Value)
-	AssignValue(expr.Name.Lexeme, value, currEnvironment)
-	return value
+	if expr.Binding != nil && expr.Binding.Local {
+		AssignSlot(expr.Binding.Depth, expr.Binding.Slot, value, i.currEnvironment)
+	} else if !AssignValue(expr.Name.Lexeme, value, i.globals) {
+		return nil, i.newRuntimeError(expr.Name, fmt.Sprintf("Undefined variable '%s'.", expr.Name.Lexeme))
+	}
+	return value, nil
 }
 
-func isFloat(value interface{}) bool {
-	switch v := value.(type) {
-	case float64:
-		return true
-	case string:
-		floatParsed, err := strconv.ParseFloat(v, 64)
-		if err == nil && !math.IsNaN(floatParsed) && !math.IsInf(floatParsed, 0) {
-			_, err := strconv.Atoi(v)
-			return err != nil
-		} else {
-			return true
-		}
-	default:
-		return false
+// lookUp returns the value of the variable name is bound to.
+func (i *Interpreter) lookUp(name models.Token, binding *models.Binding) (interface{}, error) {
+	if binding != nil && binding.Local {
+		return GetSlot(binding.Depth, binding.Slot, i.currEnvironment), nil
+	}
+
+	value, ok := GetValue(binding.RuntimeName(name).Lexeme, i.globals)
+	if !ok {
+		return nil, i.newRuntimeError(name, fmt.Sprintf("Undefined variable '%s'.", name.Lexeme))
 	}
+	return value, nil
 }
 
-func isString(value interface{}) bool {
-	return reflect.TypeOf(value) == reflect.TypeOf("")
+// define declares the variable name, in the slot of the
+// current environment binding gives it if it's local.
+func (i *Interpreter) define(name models.Token, binding *models.Binding, value interface{}) {
+	if binding != nil && binding.Local {
+		DefineSlot(binding.Slot, value, i.currEnvironment)
+	} else {
+		DefineValue(binding.RuntimeName(name).Lexeme, value, i.globals)
+	}
 }
 
 func isTruthy(value interface{}) bool {
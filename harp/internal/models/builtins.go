@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// Builtins returns the builtin functions by the names
+// they're stored under at runtime, with print writing
+// to out. Overloads like len's are in the order the
+// checker declares them.
+func Builtins(out io.Writer) map[string]Callable {
+	return map[string]Callable{
+		"clock":                  Clock{},
+		"print":                  Print{Out: out},
+		"len":                    Len{},
+		OverloadName("len", 1):   StringLen{},
+		OverloadName("len", 2):   MapLen{},
+		"append":                 Append{},
+		"pop":                    Pop{},
+		"insert":                 Insert{},
+		"slice":                  Slice{},
+		"has":                    Has{},
+		"delete":                 Delete{},
+		"keys":                   Keys{},
+		"values":                 Values{},
+		"range":                  Range{},
+		OverloadName("range", 1): Range{Stepped: true},
+	}
+}
+
+// StringLen is the overload of len for strings. It
+// counts characters, not bytes.
+type StringLen struct{}
+
+func (l StringLen) Call(arguments []interface{}) (interface{}, error) {
+	s, ok := arguments[0].(string)
+	if !ok {
+		return nil, errors.New("Cannot take the length of null.")
+	}
+	return int64(utf8.RuneCountInString(s)), nil
+}
+
+func (l StringLen) Signature() Signature {
+	return Signature{Params: []ValueType{{Kind: StringKind}}, Return: ValueType{Kind: IntKind}}
+}
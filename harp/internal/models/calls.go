@@ -0,0 +1,48 @@
+package models
+
+import "fmt"
+
+// The interpreter and the VM both limit how deeply calls to
+// Harp functions can nest, so runaway recursion stops with a
+// runtime error instead of exhausting memory, and both trim
+// the call trace of an error the same way.
+
+// DefaultMaxDepth is how deeply calls can
+// nest unless a different limit is set.
+const DefaultMaxDepth = 10000
+
+// TraceHead and TraceTail are how many of the innermost
+// and outermost calls a trimmed call trace keeps.
+const (
+	TraceHead = 5
+	TraceTail = 3
+)
+
+// StackOverflow returns the message of the error
+// raised when calls nest deeper than maxDepth.
+func StackOverflow(maxDepth int) string {
+	return fmt.Sprintf("Stack overflow: calls nested more than %d deep.", maxDepth)
+}
+
+// TrimTrace returns trace, innermost call first, with the
+// calls between its first TraceHead and last TraceTail
+// dropped, along with how many were dropped. Deep
+// recursion would otherwise bury the error under
+// thousands of identical calls.
+func TrimTrace[T any](trace []T) ([]T, int) {
+	if len(trace) <= TraceHead+TraceTail {
+		return trace, 0
+	}
+
+	trimmed := append(trace[:TraceHead:TraceHead], trace[len(trace)-TraceTail:]...)
+	return trimmed, len(trace) - len(trimmed)
+}
+
+// OmittedCalls returns the line standing in
+// for the calls dropped from a call trace.
+func OmittedCalls(omitted int) string {
+	if omitted == 1 {
+		return "... 1 more call"
+	}
+	return fmt.Sprintf("... %d more calls", omitted)
+}
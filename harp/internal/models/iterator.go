@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Iterator steps through the items of a list, map, string
+// or range for a for-each loop. The items are copied when
+// the loop starts, so changing a list or map in the body
+// doesn't change which items the loop visits. A range's
+// ints are worked out as they're reached instead.
+type Iterator struct {
+	// keys holds the index of each list element or
+	// string character, or each key of a map.
+	keys   []interface{}
+	values []interface{}
+	// ints is the range being looped over, if it is one.
+	ints *IntRange
+	// vars is how many loop variables each item
+	// fills: the value, or the key and the value.
+	vars  int
+	count uint64
+	next  uint64
+}
+
+// NewIterator returns an Iterator over iterable for a loop
+// with vars variables. With one variable, a map gives its
+// keys, and a list, string or range gives its elements.
+func NewIterator(iterable interface{}, vars int) (*Iterator, error) {
+	it := &Iterator{vars: vars}
+
+	switch iterable := iterable.(type) {
+	case *List:
+		for i, element := range iterable.Elements {
+			it.keys = append(it.keys, int64(i))
+			it.values = append(it.values, element)
+		}
+	case *Map:
+		for _, key := range iterable.keys {
+			it.keys = append(it.keys, key)
+			it.values = append(it.values, iterable.values[key])
+		}
+		// A map's keys come first, so with one
+		// variable they're what it holds.
+		if vars == 1 {
+			it.values = it.keys
+		}
+	case string:
+		// Like len, count characters rather than bytes.
+		var i int64
+		for _, char := range iterable {
+			it.keys = append(it.keys, i)
+			it.values = append(it.values, string(char))
+			i++
+		}
+	case IntRange:
+		it.ints = &iterable
+		it.count = iterable.Len()
+		return it, nil
+	case nil:
+		return nil, errors.New("Cannot loop over null.")
+	default:
+		return nil, fmt.Errorf("Cannot loop over a value of type %s.", TypeName(iterable))
+	}
+
+	it.count = uint64(len(it.keys))
+	return it, nil
+}
+
+// Next returns the loop variables for the next item,
+// or false once every item has been visited.
+func (it *Iterator) Next() ([]interface{}, bool) {
+	if it.next == it.count {
+		return nil, false
+	}
+
+	i := it.next
+	it.next++
+
+	var key, value interface{}
+	if it.ints != nil {
+		key, value = int64(i), it.ints.At(i)
+	} else {
+		key, value = it.keys[i], it.values[i]
+	}
+
+	if it.vars == 1 {
+		return []interface{}{value}, true
+	}
+	return []interface{}{key, value}, true
+}
+
+// IntRange is a range value, the ints from Start up to but
+// not including End, counting by Step. Its ints are never
+// stored, so a range over any span of ints is cheap.
+type IntRange struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+// Len returns how many ints r holds. The sums are done
+// on uint64s so that spans wider than an int64 can hold,
+// like the one from the smallest int to the largest,
+// don't overflow.
+func (r IntRange) Len() uint64 {
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		return (uint64(r.End)-uint64(r.Start)-1)/uint64(r.Step) + 1
+	case r.Step < 0 && r.Start > r.End:
+		return (uint64(r.Start)-uint64(r.End)-1)/-uint64(r.Step) + 1
+	}
+	return 0
+}
+
+// At returns the int at index i of r, which
+// must be less than r.Len().
+func (r IntRange) At(i uint64) int64 {
+	return int64(uint64(r.Start) + i*uint64(r.Step))
+}
+
+// String shows r the way it's made,
+// leaving out a step of 1.
+func (r IntRange) String() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Range is the range builtin, which returns an IntRange of
+// the ints from a start up to but not including an end. The
+// overload with Stepped set takes a third argument to count
+// by, which counts down if it's negative.
+type Range struct {
+	Stepped bool
+}
+
+func (r Range) Call(arguments []interface{}) (interface{}, error) {
+	start, ok := arguments[0].(int64)
+	end, endOk := arguments[1].(int64)
+	if !ok || !endOk {
+		return nil, errors.New("Range bounds cannot be null.")
+	}
+
+	step := int64(1)
+	if r.Stepped {
+		if step, ok = arguments[2].(int64); !ok {
+			return nil, errors.New("Range step cannot be null.")
+		}
+		if step == 0 {
+			return nil, errors.New("Range step cannot be 0.")
+		}
+	}
+
+	return IntRange{Start: start, End: end, Step: step}, nil
+}
+
+func (r Range) Signature() Signature {
+	params := []ValueType{{Kind: IntKind}, {Kind: IntKind}}
+	if r.Stepped {
+		params = append(params, ValueType{Kind: IntKind})
+	}
+	return Signature{Params: params, Return: ValueType{Kind: RangeKind}}
+}
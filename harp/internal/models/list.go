@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// List is a Harp list value. Lists are shared
+// by reference, so builtins change them in place.
+type List struct {
+	Elements []interface{}
+}
+
+func (l *List) String() string {
+	return Format(l)
+}
+
+// CheckIndex converts index to an int and returns an
+// error if it's out of range for a list of length length.
+func CheckIndex(index interface{}, length int) (int, error) {
+	i, ok := index.(int64)
+	if !ok {
+		return 0, errors.New("List index must be an int.")
+	}
+	if i < 0 || i >= int64(length) {
+		return 0, fmt.Errorf("Index %d out of range for list of length %d.", i, length)
+	}
+	return int(i), nil
+}
+
+// anyList is the type of the list parameters of the
+// list builtins, which take lists of any element type.
+var anyList = ValueType{Kind: ListKind}
+
+type Len struct{}
+
+func (l Len) Call(arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Cannot take the length of null.")
+	}
+	return int64(len(list.Elements)), nil
+}
+
+func (l Len) Signature() Signature {
+	return Signature{Params: []ValueType{anyList}, Return: ValueType{Kind: IntKind}}
+}
+
+type Append struct{}
+
+func (a Append) Call(arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Cannot append to null.")
+	}
+	list.Elements = append(list.Elements, arguments[1])
+	return nil, nil
+}
+
+func (a Append) Signature() Signature {
+	return Signature{Params: []ValueType{anyList, {Kind: AnyKind}}, Return: ValueType{Kind: VoidKind}}
+}
+
+type Pop struct{}
+
+func (p Pop) Call(arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Cannot pop from null.")
+	}
+	if len(list.Elements) == 0 {
+		return nil, errors.New("Cannot pop from an empty list.")
+	}
+
+	last := list.Elements[len(list.Elements)-1]
+	list.Elements = list.Elements[:len(list.Elements)-1]
+	return last, nil
+}
+
+func (p Pop) Signature() Signature {
+	return Signature{Params: []ValueType{anyList}, Return: ValueType{Kind: AnyKind}}
+}
+
+type Insert struct{}
+
+func (i Insert) Call(arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Cannot insert into null.")
+	}
+
+	index, ok := arguments[1].(int64)
+	if !ok {
+		return nil, errors.New("List index must be an int.")
+	}
+	// Inserting at the very end is allowed.
+	if index < 0 || index > int64(len(list.Elements)) {
+		return nil, fmt.Errorf("Index %d out of range for inserting into list of length %d.", index, len(list.Elements))
+	}
+
+	list.Elements = append(list.Elements, nil)
+	copy(list.Elements[index+1:], list.Elements[index:])
+	list.Elements[index] = arguments[2]
+	return nil, nil
+}
+
+func (i Insert) Signature() Signature {
+	return Signature{Params: []ValueType{anyList, {Kind: IntKind}, {Kind: AnyKind}}, Return: ValueType{Kind: VoidKind}}
+}
+
+type Slice struct{}
+
+func (s Slice) Call(arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, errors.New("Cannot slice null.")
+	}
+
+	start, ok := arguments[1].(int64)
+	if !ok {
+		return nil, errors.New("Slice bounds must be ints.")
+	}
+	end, ok := arguments[2].(int64)
+	if !ok {
+		return nil, errors.New("Slice bounds must be ints.")
+	}
+	if start < 0 || end > int64(len(list.Elements)) || start > end {
+		return nil, fmt.Errorf("Slice [%d:%d] out of range for list of length %d.", start, end, len(list.Elements))
+	}
+
+	elements := make([]interface{}, end-start)
+	copy(elements, list.Elements[start:end])
+	return &List{Elements: elements}, nil
+}
+
+func (s Slice) Signature() Signature {
+	return Signature{Params: []ValueType{anyList, {Kind: IntKind}, {Kind: IntKind}}, Return: anyList}
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Map is a Harp map value. Like lists, maps are shared
+// by reference. Keys are kept in the order they were
+// first added, so keys, values and printing are the
+// same every run.
+type Map struct {
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+// NewMap returns an empty map.
+func NewMap() *Map {
+	return &Map{values: map[interface{}]interface{}{}}
+}
+
+// Get returns the value stored under key and
+// whether there is one.
+func (m *Map) Get(key interface{}) (interface{}, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set stores value under key. A new key goes after
+// every other one, but replacing the value of an
+// existing key keeps its place.
+func (m *Map) Set(key interface{}, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key and its value, if it's there.
+func (m *Map) Delete(key interface{}) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Len returns how many keys m has.
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of m in order. Changing
+// the result doesn't change m.
+func (m *Map) Keys() []interface{} {
+	return append([]interface{}(nil), m.keys...)
+}
+
+func (m *Map) String() string {
+	return Format(m)
+}
+
+// CheckKey returns an error if key can't be
+// used as a map key. The checker only allows
+// hashable key types, but null still gets by,
+// and NaN is never equal to itself, so a NaN
+// key could be set but never found again.
+func CheckKey(key interface{}) error {
+	if key == nil {
+		return errors.New("Map key cannot be null.")
+	}
+	if f, ok := key.(float64); ok && math.IsNaN(f) {
+		return errors.New("Map key cannot be NaN.")
+	}
+	return nil
+}
+
+// formatKey returns key as it's shown in errors,
+// with strings quoted so "" can be told apart.
+func formatKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(key)
+}
+
+// GetIndex returns object[index], where object
+// is a list or a map.
+func GetIndex(object interface{}, index interface{}) (interface{}, error) {
+	switch object := object.(type) {
+	case *List:
+		i, err := CheckIndex(index, len(object.Elements))
+		if err != nil {
+			return nil, err
+		}
+		return object.Elements[i], nil
+	case *Map:
+		if err := CheckKey(index); err != nil {
+			return nil, err
+		}
+		value, ok := object.Get(index)
+		if !ok {
+			return nil, fmt.Errorf("Key %s not found in map.", formatKey(index))
+		}
+		return value, nil
+	}
+
+	return nil, errors.New("Can only index lists and maps.")
+}
+
+// SetIndex stores value at object[index], where object
+// is a list or a map. Unlike lists, maps get a new
+// entry when index isn't already in them.
+func SetIndex(object interface{}, index interface{}, value interface{}) error {
+	switch object := object.(type) {
+	case *List:
+		i, err := CheckIndex(index, len(object.Elements))
+		if err != nil {
+			return err
+		}
+		object.Elements[i] = value
+		return nil
+	case *Map:
+		if err := CheckKey(index); err != nil {
+			return err
+		}
+		object.Set(index, value)
+		return nil
+	}
+
+	return errors.New("Can only index lists and maps.")
+}
+
+// anyMap is the type of the map parameters of the
+// map builtins, which take maps of any types.
+var anyMap = ValueType{Kind: MapKind}
+
+type Has struct{}
+
+func (h Has) Call(arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Cannot look up a key in null.")
+	}
+	if err := CheckKey(arguments[1]); err != nil {
+		return nil, err
+	}
+	_, found := m.Get(arguments[1])
+	return found, nil
+}
+
+func (h Has) Signature() Signature {
+	return Signature{Params: []ValueType{anyMap, {Kind: AnyKind}}, Return: ValueType{Kind: BoolKind}}
+}
+
+type Delete struct{}
+
+func (d Delete) Call(arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Cannot delete from null.")
+	}
+	if err := CheckKey(arguments[1]); err != nil {
+		return nil, err
+	}
+	m.Delete(arguments[1])
+	return nil, nil
+}
+
+func (d Delete) Signature() Signature {
+	return Signature{Params: []ValueType{anyMap, {Kind: AnyKind}}, Return: ValueType{Kind: VoidKind}}
+}
+
+type Keys struct{}
+
+func (k Keys) Call(arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Cannot get the keys of null.")
+	}
+	return &List{Elements: m.Keys()}, nil
+}
+
+func (k Keys) Signature() Signature {
+	return Signature{Params: []ValueType{anyMap}, Return: anyList}
+}
+
+type Values struct{}
+
+func (v Values) Call(arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Cannot get the values of null.")
+	}
+
+	values := make([]interface{}, 0, m.Len())
+	for _, key := range m.keys {
+		values = append(values, m.values[key])
+	}
+	return &List{Elements: values}, nil
+}
+
+func (v Values) Signature() Signature {
+	return Signature{Params: []ValueType{anyMap}, Return: anyList}
+}
+
+// MapLen is the overload of len for maps.
+type MapLen struct{}
+
+func (l MapLen) Call(arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, errors.New("Cannot take the length of null.")
+	}
+	return int64(m.Len()), nil
+}
+
+func (l MapLen) Signature() Signature {
+	return Signature{Params: []ValueType{anyMap}, Return: ValueType{Kind: IntKind}}
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"fmt"
+	"io"
+	"strings"
 	"time"
 )
 
@@ -9,8 +11,13 @@ type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal interface{}
-	Column  int
-	Line    int
+	// Offset is the byte offset of the token in the
+	// source, and Line and Column where it starts.
+	Offset int
+	Column int
+	Line   int
+	// End is the position just after the token.
+	End Position
 }
 
 type TokenType int
@@ -27,8 +34,10 @@ const (
 	MINUS
 	PLUS
 	SEMICOLON
+	COLON
 	SLASH
 	STAR
+	PERCENT
 
 	BANG
 	BANG_EQUAL
@@ -38,6 +47,13 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	PLUS_EQUAL
+	MINUS_EQUAL
+	STAR_EQUAL
+	SLASH_EQUAL
+	PERCENT_EQUAL
+	PLUS_PLUS
+	MINUS_MINUS
 
 	IDENTIFIER
 	STRING
@@ -56,11 +72,16 @@ const (
 	TRUE
 	WHILE
 	STRUCT
+	BREAK
+	CONTINUE
+	IN
 
 	STRING_VAR
 	INT_VAR
 	DOUBLE_VAR
 	BOOL_VAR
+	LIST_VAR
+	MAP_VAR
 
 	EOF
 )
@@ -77,8 +98,10 @@ var TokenTypesNames = map[TokenType]string{
 	MINUS:        "MINUS",
 	PLUS:         "PLUS",
 	SEMICOLON:    "SEMICOLON",
+	COLON:        "COLON",
 	SLASH:        "SLASH",
 	STAR:         "STAR",
+	PERCENT:      "PERCENT",
 
 	BANG:          "BANG",
 	BANG_EQUAL:    "BANG_EQUAL",
@@ -88,39 +111,61 @@ var TokenTypesNames = map[TokenType]string{
 	GREATER_EQUAL: "GREATER_EQUAL",
 	LESS:          "LESS",
 	LESS_EQUAL:    "LESS_EQUAL",
+	PLUS_EQUAL:    "PLUS_EQUAL",
+	MINUS_EQUAL:   "MINUS_EQUAL",
+	STAR_EQUAL:    "STAR_EQUAL",
+	SLASH_EQUAL:   "SLASH_EQUAL",
+	PERCENT_EQUAL: "PERCENT_EQUAL",
+	PLUS_PLUS:     "PLUS_PLUS",
+	MINUS_MINUS:   "MINUS_MINUS",
 
 	IDENTIFIER: "IDENTIFIER",
 	STRING:     "STRING",
 	INT:        "INT",
 	DOUBLE:     "DOUBLE",
 
-	AND:    "AND",
-	ELSE:   "ELSE",
-	FALSE:  "FALSE",
-	FUNC:   "FUNC",
-	FOR:    "FOR",
-	IF:     "IF",
-	NULL:   "NULL",
-	OR:     "OR",
-	RETURN: "RETURN",
-	TRUE:   "TRUE",
-	WHILE:  "WHILE",
-	STRUCT: "STRUCT",
+	AND:      "AND",
+	ELSE:     "ELSE",
+	FALSE:    "FALSE",
+	FUNC:     "FUNC",
+	FOR:      "FOR",
+	IF:       "IF",
+	NULL:     "NULL",
+	OR:       "OR",
+	RETURN:   "RETURN",
+	TRUE:     "TRUE",
+	WHILE:    "WHILE",
+	STRUCT:   "STRUCT",
+	BREAK:    "BREAK",
+	CONTINUE: "CONTINUE",
+	IN:       "IN",
 
 	STRING_VAR: "STRING_VAR",
 	INT_VAR:    "INT_VAR",
 	DOUBLE_VAR: "DOUBLE_VAR",
 	BOOL_VAR:   "BOOL_VAR",
+	LIST_VAR:   "LIST_VAR",
+	MAP_VAR:    "MAP_VAR",
 
 	EOF: "",
 }
 
+// Expr is an expression. Only the node types in this
+// package implement it, so a visitor covers them all.
 type Expr interface {
+	Accept(visitor ExprVisitor) (interface{}, error)
+	Span() Span
+	exprNode()
 }
 
 type AssignExpr struct {
 	Name  Token
 	Value Expr
+	// Operator is the arithmetic operator of a compound
+	// assignment like x += 1, which is lowered to an
+	// assignment that applies it, or nil for '='.
+	Operator *Token
+	Binding  *Binding
 }
 
 type BinaryExpr struct {
@@ -135,15 +180,19 @@ type UnaryExpr struct {
 }
 
 type LiteralExpr struct {
+	Token   Token
 	Literal interface{}
 }
 
 type GroupingExpr struct {
+	LeftParen  Token
 	Expression Expr
+	RightParen Token
 }
 
 type VarExpr struct {
-	Name Token
+	Name    Token
+	Binding *Binding
 }
 
 type LogicExpr struct {
@@ -158,7 +207,132 @@ type CallExpr struct {
 	Arguments []Expr
 }
 
+type ListExpr struct {
+	Bracket      Token
+	Elements     []Expr
+	RightBracket Token
+}
+
+// MapExpr is a map literal like {"a": 1}.
+type MapExpr struct {
+	Brace      Token
+	Entries    []MapEntry
+	RightBrace Token
+}
+
+type MapEntry struct {
+	Key   Expr
+	Value Expr
+}
+
+type IndexExpr struct {
+	Object       Expr
+	Bracket      Token
+	Index        Expr
+	RightBracket Token
+}
+
+type IndexSetExpr struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+	Value   Expr
+	// Operator is as in AssignExpr. Object and
+	// Index are still only evaluated once.
+	Operator *Token
+}
+
+type GetExpr struct {
+	Object Expr
+	Name   Token
+}
+
+type SetExpr struct {
+	Object Expr
+	Name   Token
+	Value  Expr
+	// Operator is as in AssignExpr.
+	Operator *Token
+}
+
+type StructExpr struct {
+	Name       Token
+	Fields     []FieldInit
+	RightBrace Token
+	Binding    *Binding
+}
+
+// FuncExpr is an anonymous function.
+type FuncExpr struct {
+	Keyword    Token
+	Params     []FuncParam
+	ReturnType *TypeExpr
+	Body       []Stmt
+	RightBrace Token
+}
+
+// FieldInit sets one field in a struct literal.
+type FieldInit struct {
+	Name  Token
+	Value Expr
+}
+
+// TypeExpr is a type as written in source, such as
+// int, list<int>, func(int) bool or the name of a
+// struct. For a function type, Args holds the parameter
+// types and Return the result type, or nil for none.
+// Last is the last token of the type.
+type TypeExpr struct {
+	Name   Token
+	Args   []TypeExpr
+	Return *TypeExpr
+	Last   Token
+}
+
+// Binding is where the variable a name refers to lives.
+// The parser gives each name an empty Binding, meaning a
+// global looked up by name, and the resolver fills in
+// the slot of names that refer to local variables.
+type Binding struct {
+	Local bool
+	// Depth is how many scopes out from the one the
+	// name appears in the variable was declared.
+	Depth int
+	Slot  int
+	// Overload is the index of the overload of a function
+	// the name declares or refers to, in the order they're
+	// declared. The checker fills it in.
+	Overload int
+}
+
+// RuntimeName returns name as it's stored at runtime.
+// Every overload of a function but the first has its
+// index added, so each has a variable of its own.
+func (b *Binding) RuntimeName(name Token) Token {
+	if b == nil || b.Overload == 0 {
+		return name
+	}
+	name.Lexeme = OverloadName(name.Lexeme, b.Overload)
+	return name
+}
+
+// OverloadName returns the name the overload with index
+// overload of the function name is stored under.
+func OverloadName(name string, overload int) string {
+	if overload == 0 {
+		return name
+	}
+	// '#' can't be in an identifier, so this can't
+	// clash with a name in the program.
+	return fmt.Sprintf("%s#%d", name, overload)
+}
+
+// Stmt is a statement. Like Expr, only the node
+// types in this package implement it.
 type Stmt interface {
+	Accept(visitor StmtVisitor) (interface{}, error)
+	Span() Span
+	stmtNode()
 }
 
 type ExprStmt struct {
@@ -166,29 +340,103 @@ type ExprStmt struct {
 }
 
 type VarStmt struct {
+	Type        TypeExpr
 	Name        Token
 	Initializer Expr
+	Binding     *Binding
 }
 
+// BlockStmt is a list of statements run in a scope of
+// their own. The parser also makes blocks with no braces
+// when it desugars for loops.
 type BlockStmt struct {
+	LeftBrace  Token
 	Statements []Stmt
+	RightBrace Token
 }
 
 type IfStmt struct {
+	Keyword    Token
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
 }
 
 type WhileStmt struct {
+	Keyword   Token
 	Condition Expr
 	Body      Stmt
+	// Increment is the increment clause of a for loop,
+	// which runs after the body and after a continue.
+	// It's nil for while loops.
+	Increment Expr
+	// Label names the loop for break and continue
+	// statements in nested loops, or is nil.
+	Label *Token
 }
 
 type FuncStmt struct {
-	Name   Token
-	Params []FuncParam
-	Body   []Stmt
+	Keyword    Token
+	Name       Token
+	Params     []FuncParam
+	ReturnType *TypeExpr
+	Body       []Stmt
+	RightBrace Token
+	Binding    *Binding
+}
+
+type ReturnStmt struct {
+	Keyword Token
+	Value   Expr
+}
+
+// ForEachStmt is a loop like for (int x in xs) that runs
+// Body once for each item of Iterable, which is a list, a
+// map or a string. Vars holds one variable, or two for
+// the index and element of a list or string or the key
+// and value of a map. Each iteration gets its own
+// variables, so closures made in the body keep the
+// values of the iteration they were made in.
+type ForEachStmt struct {
+	Keyword  Token
+	Vars     []LoopVar
+	In       Token
+	Iterable Expr
+	Body     Stmt
+	// Label is as in WhileStmt.
+	Label *Token
+}
+
+type LoopVar struct {
+	Type TypeExpr
+	Name Token
+}
+
+// BreakStmt leaves the loop named Label, or the
+// innermost loop if Label is nil.
+type BreakStmt struct {
+	Keyword Token
+	Label   *Token
+}
+
+// ContinueStmt skips to the next iteration of the loop
+// named Label, or the innermost loop if Label is nil.
+type ContinueStmt struct {
+	Keyword Token
+	Label   *Token
+}
+
+type StructStmt struct {
+	Keyword    Token
+	Name       Token
+	Fields     []StructField
+	RightBrace Token
+	Binding    *Binding
+}
+
+type StructField struct {
+	Type TypeExpr
+	Name Token
 }
 
 type ErrorStmt struct{}
@@ -196,13 +444,8 @@ type ErrorStmt struct{}
 type ErrorExpr struct{}
 
 type FuncParam struct {
-	Type TokenType
-	Name string
-}
-
-type Callable interface {
-	Call(arguments []Expr) interface{}
-	Arity() int
+	Type TypeExpr
+	Name Token
 }
 
 type Function struct {
@@ -211,35 +454,85 @@ type Function struct {
 	Body   []Stmt
 }
 
-func (f Function) Arity() int {
-	return len(f.Params)
-}
+type Clock struct{}
 
-func (f Function) Call(arguments []Expr) interface{} {
-	panic("Call method not implemented.")
+func (c Clock) Call(arguments []interface{}) (interface{}, error) {
+	return time.Now().UnixMilli(), nil
 }
 
-type Clock struct{}
+func (c Clock) Signature() Signature {
+	return Signature{Return: ValueType{Kind: IntKind}}
+}
 
-func (c Clock) Call(arguments []Expr) interface{} {
-	return int(time.Now().UnixMilli())
+// Format returns value as Harp shows it,
+// with nil written as null.
+func Format(value interface{}) string {
+	return format(value, map[interface{}]bool{})
 }
 
-func (c Clock) Arity() int {
-	return 0
+// format formats value for Format. printing holds the
+// lists, maps and instances value is nested in, so one
+// that holds itself is shown as [...], {...} or Name{...}
+// where it repeats instead of being formatted forever.
+func format(value interface{}, printing map[interface{}]bool) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case *List:
+		if printing[v] {
+			return "[...]"
+		}
+		printing[v] = true
+		defer delete(printing, v)
+
+		var elements []string
+		for _, element := range v.Elements {
+			elements = append(elements, format(element, printing))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Map:
+		if printing[v] {
+			return "{...}"
+		}
+		printing[v] = true
+		defer delete(printing, v)
+
+		var entries []string
+		for _, key := range v.keys {
+			entries = append(entries, fmt.Sprint(key)+": "+format(v.values[key], printing))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case *Instance:
+		if printing[v] {
+			return v.Struct.Name + "{...}"
+		}
+		printing[v] = true
+		defer delete(printing, v)
+
+		var fields []string
+		for _, field := range v.Struct.Fields {
+			fields = append(fields, field+": "+format(v.Fields[field], printing))
+		}
+		return v.Struct.Name + "{" + strings.Join(fields, ", ") + "}"
+	}
+	return fmt.Sprint(value)
 }
 
-type Print struct{}
+// Print writes its arguments to Out, separated
+// by spaces, and then a newline.
+type Print struct {
+	Out io.Writer
+}
 
-func (p Print) Call(arguments []Expr) interface{} {
-	if len(arguments) == 0 {
-		fmt.Println()
-	} else {
-		fmt.Println(arguments[0])
+func (p Print) Call(arguments []interface{}) (interface{}, error) {
+	values := make([]string, len(arguments))
+	for i, argument := range arguments {
+		values[i] = Format(argument)
 	}
-	return nil
+	fmt.Fprintln(p.Out, strings.Join(values, " "))
+	return nil, nil
 }
 
-func (p Print) Arity() int {
-	return 1
+func (p Print) Signature() Signature {
+	return Signature{Params: []ValueType{{Kind: AnyKind}}, Variadic: true, Return: ValueType{Kind: VoidKind}}
 }
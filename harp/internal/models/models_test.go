@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+func TestFormat(t *testing.T) {
+	list := &models.List{Elements: []interface{}{int64(1)}}
+	list.Elements = append(list.Elements, list)
+
+	m := models.NewMap()
+	m.Set("self", m)
+	m.Set("list", list)
+
+	node := &models.Struct{Name: "Node", Fields: []string{"next"}}
+	instance := &models.Instance{Struct: node, Fields: map[string]interface{}{}}
+	instance.Fields["next"] = instance
+
+	shared := &models.List{Elements: []interface{}{"a"}}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"null", nil, "null"},
+		{"double", 1.5, "1.5"},
+		{"list holding itself", list, "[1, [...]]"},
+		{"map holding itself", m, "{self: {...}, list: [1, [...]]}"},
+		{"instance holding itself", instance, "Node{next: Node{...}}"},
+		{"shared but not nested", &models.List{Elements: []interface{}{shared, shared}}, "[[a], [a]]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := models.Format(test.value); got != test.want {
+				t.Errorf("Format = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
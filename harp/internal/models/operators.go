@@ -0,0 +1,174 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// The interpreter and the VM both use the functions here
+// for arithmetic and comparison, so they agree on every
+// result. The checker makes sure the operands of each
+// operator are both ints, both doubles or, for '+', both
+// strings. Values are never converted between types.
+
+var operatorLexemes = map[TokenType]string{
+	PLUS:          "+",
+	MINUS:         "-",
+	STAR:          "*",
+	SLASH:         "/",
+	PERCENT:       "%",
+	LESS:          "<",
+	LESS_EQUAL:    "<=",
+	GREATER:       ">",
+	GREATER_EQUAL: ">=",
+}
+
+// Binary applies the arithmetic or comparison operator op to
+// left and right. Int arithmetic that overflows and int
+// division by zero are errors. Double arithmetic follows
+// IEEE 754, so dividing by zero gives an infinity and any
+// comparison with NaN is false.
+func Binary(op TokenType, left interface{}, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case int64:
+		if r, ok := right.(int64); ok {
+			return intBinary(op, l, r)
+		}
+	case float64:
+		if r, ok := right.(float64); ok {
+			return floatBinary(op, l, r)
+		}
+	case string:
+		if r, ok := right.(string); ok && op == PLUS {
+			return l + r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid operands to '%s': %s and %s.", operatorLexemes[op], TypeName(left), TypeName(right))
+}
+
+func intBinary(op TokenType, left int64, right int64) (interface{}, error) {
+	switch op {
+	case PLUS:
+		result := left + right
+		if (right > 0 && result < left) || (right < 0 && result > left) {
+			return nil, overflowError(op, left, right)
+		}
+		return result, nil
+	case MINUS:
+		result := left - right
+		if (right > 0 && result > left) || (right < 0 && result < left) {
+			return nil, overflowError(op, left, right)
+		}
+		return result, nil
+	case STAR:
+		if left == 0 || right == 0 {
+			return int64(0), nil
+		}
+		result := left * right
+		if result/right != left || (left == -1 && right == math.MinInt64) || (right == -1 && left == math.MinInt64) {
+			return nil, overflowError(op, left, right)
+		}
+		return result, nil
+	case SLASH:
+		if right == 0 {
+			return nil, fmt.Errorf("Division by zero.")
+		}
+		if left == math.MinInt64 && right == -1 {
+			return nil, overflowError(op, left, right)
+		}
+		// Int division rounds toward zero.
+		return left / right, nil
+	case PERCENT:
+		if right == 0 {
+			return nil, fmt.Errorf("Division by zero.")
+		}
+		// The remainder has the sign of left.
+		return left % right, nil
+	case LESS:
+		return left < right, nil
+	case LESS_EQUAL:
+		return left <= right, nil
+	case GREATER:
+		return left > right, nil
+	case GREATER_EQUAL:
+		return left >= right, nil
+	}
+
+	return nil, fmt.Errorf("Unknown operator '%s'.", operatorLexemes[op])
+}
+
+func floatBinary(op TokenType, left float64, right float64) (interface{}, error) {
+	switch op {
+	case PLUS:
+		return left + right, nil
+	case MINUS:
+		return left - right, nil
+	case STAR:
+		return left * right, nil
+	case SLASH:
+		return left / right, nil
+	case PERCENT:
+		return math.Mod(left, right), nil
+	case LESS:
+		return left < right, nil
+	case LESS_EQUAL:
+		return left <= right, nil
+	case GREATER:
+		return left > right, nil
+	case GREATER_EQUAL:
+		return left >= right, nil
+	}
+
+	return nil, fmt.Errorf("Unknown operator '%s'.", operatorLexemes[op])
+}
+
+// Negate returns -value for an int or double.
+func Negate(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int64:
+		if v == math.MinInt64 {
+			return nil, fmt.Errorf("Integer overflow in -(%d).", v)
+		}
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+
+	return nil, fmt.Errorf("Operand of '-' must be int or double, got %s.", TypeName(value))
+}
+
+func overflowError(op TokenType, left int64, right int64) error {
+	return fmt.Errorf("Integer overflow in %d %s %d.", left, operatorLexemes[op], right)
+}
+
+// TypeName returns the name of the type of
+// the runtime value value, for error messages.
+func TypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case int64:
+		return "int"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case *List:
+		return "list"
+	case *Map:
+		return "map"
+	case *Instance:
+		return v.Struct.Name
+	case *Struct:
+		return "struct " + v.Name
+	case IntRange:
+		return "range"
+	case Callable:
+		return "func"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,96 @@
+package models_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+func TestBinary(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    models.TokenType
+		left  interface{}
+		right interface{}
+		want  interface{}
+		err   string
+	}{
+		{"add", models.PLUS, int64(2), int64(3), int64(5), ""},
+		{"add up to the max", models.PLUS, int64(math.MaxInt64 - 1), int64(1), int64(math.MaxInt64), ""},
+		{"add past the max", models.PLUS, int64(math.MaxInt64), int64(1), nil, "Integer overflow in 9223372036854775807 + 1."},
+		{"add past the min", models.PLUS, int64(math.MinInt64), int64(-1), nil, "Integer overflow in -9223372036854775808 + -1."},
+		{"subtract past the min", models.MINUS, int64(math.MinInt64), int64(1), nil, "Integer overflow in -9223372036854775808 - 1."},
+		{"subtract past the max", models.MINUS, int64(math.MaxInt64), int64(-1), nil, "Integer overflow in 9223372036854775807 - -1."},
+		{"subtract down to the min", models.MINUS, int64(-1), int64(math.MaxInt64), int64(math.MinInt64), ""},
+		{"multiply", models.STAR, int64(-4), int64(5), int64(-20), ""},
+		{"multiply by zero", models.STAR, int64(math.MinInt64), int64(0), int64(0), ""},
+		{"multiply past the max", models.STAR, int64(math.MaxInt64/2 + 1), int64(2), nil, "Integer overflow in 4611686018427387904 * 2."},
+		{"multiply the min by -1", models.STAR, int64(math.MinInt64), int64(-1), nil, "Integer overflow in -9223372036854775808 * -1."},
+		{"multiply -1 by the min", models.STAR, int64(-1), int64(math.MinInt64), nil, "Integer overflow in -1 * -9223372036854775808."},
+		{"divide toward zero", models.SLASH, int64(-7), int64(2), int64(-3), ""},
+		{"divide by zero", models.SLASH, int64(1), int64(0), nil, "Division by zero."},
+		{"divide the min by -1", models.SLASH, int64(math.MinInt64), int64(-1), nil, "Integer overflow in -9223372036854775808 / -1."},
+		{"modulo takes the sign of the left", models.PERCENT, int64(-7), int64(3), int64(-1), ""},
+		{"modulo the min by -1", models.PERCENT, int64(math.MinInt64), int64(-1), int64(0), ""},
+		{"modulo by zero", models.PERCENT, int64(7), int64(0), nil, "Division by zero."},
+		{"double divide by zero", models.SLASH, 1.0, 0.0, math.Inf(1), ""},
+		{"double modulo", models.PERCENT, 7.5, 2.0, 1.5, ""},
+		{"compare ints", models.LESS_EQUAL, int64(2), int64(2), true, ""},
+		{"compare with NaN", models.GREATER_EQUAL, math.NaN(), 1.0, false, ""},
+		{"join strings", models.PLUS, "a", "b", "ab", ""},
+		{"mixed operands", models.PLUS, int64(1), 1.0, nil, "Invalid operands to '+': int and double."},
+		{"subtract strings", models.MINUS, "a", "b", nil, "Invalid operands to '-': string and string."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := models.Binary(test.op, test.left, test.right)
+			checkResult(t, got, err, test.want, test.err)
+		})
+	}
+}
+
+func TestNegate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+		err   string
+	}{
+		{"int", int64(5), int64(-5), ""},
+		{"max int", int64(math.MaxInt64), int64(-math.MaxInt64), ""},
+		{"min int", int64(math.MinInt64), nil, "Integer overflow in -(-9223372036854775808)."},
+		{"double", 1.5, -1.5, ""},
+		{"string", "a", nil, "Operand of '-' must be int or double, got string."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := models.Negate(test.value)
+			checkResult(t, got, err, test.want, test.err)
+		})
+	}
+}
+
+// checkResult checks an operator gave want, or
+// failed with the message wantErr if it isn't "".
+func checkResult(t *testing.T, got interface{}, err error, want interface{}, wantErr string) {
+	t.Helper()
+
+	if wantErr != "" {
+		if err == nil {
+			t.Fatalf("got %#v, want error %q", got, wantErr)
+		}
+		if err.Error() != wantErr {
+			t.Errorf("error = %q, want %q", err.Error(), wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeKind is the kind of a ValueType.
+type TypeKind int
+
+const (
+	// AnyKind matches every value. Builtins
+	// that take any type use it.
+	AnyKind TypeKind = iota
+	IntKind
+	DoubleKind
+	StringKind
+	BoolKind
+	ListKind
+	MapKind
+	StructKind
+	FuncKind
+	RangeKind
+	VoidKind
+)
+
+// ValueType is a type as seen at runtime, used to check
+// the arguments of calls the checker couldn't. Elem is
+// the element type of a list or the value type of a
+// map, or nil for one of anything, Key is the key type
+// of a map and Name is the name of a struct.
+type ValueType struct {
+	Kind TypeKind
+	Elem *ValueType
+	Key  *ValueType
+	Name string
+}
+
+// Signature describes the parameters and
+// result of something that can be called.
+type Signature struct {
+	Params []ValueType
+	// Variadic is whether the last parameter takes any
+	// number of arguments, including none.
+	Variadic bool
+	Return   ValueType
+}
+
+// Callable is a value Harp code can call.
+type Callable interface {
+	Call(arguments []interface{}) (interface{}, error)
+	Signature() Signature
+}
+
+func (t ValueType) String() string {
+	switch t.Kind {
+	case IntKind:
+		return "int"
+	case DoubleKind:
+		return "double"
+	case StringKind:
+		return "string"
+	case BoolKind:
+		return "bool"
+	case ListKind:
+		if t.Elem == nil {
+			return "list"
+		}
+		return "list<" + t.Elem.String() + ">"
+	case MapKind:
+		if t.Key == nil || t.Elem == nil {
+			return "map"
+		}
+		return "map<" + t.Key.String() + ", " + t.Elem.String() + ">"
+	case StructKind:
+		return t.Name
+	case FuncKind:
+		return "func"
+	case RangeKind:
+		return "range"
+	case VoidKind:
+		return "void"
+	}
+
+	return "any"
+}
+
+// Matches reports whether value has type t. Like in the
+// checker, null only matches the types that can hold it.
+// Only the outside of a value is looked at, so the
+// elements of a list aren't checked.
+func (t ValueType) Matches(value interface{}) bool {
+	if value == nil {
+		return t.Nullable()
+	}
+
+	switch t.Kind {
+	case IntKind:
+		_, ok := value.(int64)
+		return ok
+	case DoubleKind:
+		_, ok := value.(float64)
+		return ok
+	case StringKind:
+		_, ok := value.(string)
+		return ok
+	case BoolKind:
+		_, ok := value.(bool)
+		return ok
+	case ListKind:
+		_, ok := value.(*List)
+		return ok
+	case MapKind:
+		_, ok := value.(*Map)
+		return ok
+	case StructKind:
+		instance, ok := value.(*Instance)
+		return ok && instance.Struct.Name == t.Name
+	case FuncKind:
+		_, ok := value.(interface{ Signature() Signature })
+		return ok
+	case RangeKind:
+		_, ok := value.(IntRange)
+		return ok
+	case VoidKind:
+		return false
+	}
+
+	return true
+}
+
+// Nullable reports whether a value of type t can be null.
+// Ints, doubles, strings, bools and ranges never are.
+func (t ValueType) Nullable() bool {
+	switch t.Kind {
+	case IntKind, DoubleKind, StringKind, BoolKind, RangeKind:
+		return false
+	}
+	return true
+}
+
+// Zero returns the value a variable of type t
+// starts with when it isn't given one.
+func (t ValueType) Zero() interface{} {
+	switch t.Kind {
+	case IntKind:
+		return int64(0)
+	case DoubleKind:
+		return 0.0
+	case StringKind:
+		return ""
+	case BoolKind:
+		return false
+	case RangeKind:
+		return IntRange{Step: 1}
+	}
+	return nil
+}
+
+func (s Signature) String() string {
+	var params []string
+	for i, param := range s.Params {
+		if s.Variadic && i == len(s.Params)-1 {
+			params = append(params, param.String()+"...")
+		} else {
+			params = append(params, param.String())
+		}
+	}
+
+	if s.Return.Kind == VoidKind {
+		return "func(" + strings.Join(params, ", ") + ")"
+	}
+	return "func(" + strings.Join(params, ", ") + ") " + s.Return.String()
+}
+
+// CheckArguments returns an error if arguments
+// can't be passed to something with signature s.
+func (s Signature) CheckArguments(arguments []interface{}) error {
+	if s.Variadic {
+		if len(arguments) < len(s.Params)-1 {
+			return fmt.Errorf("Expected at least %s but got %d.", Arguments(len(s.Params)-1), len(arguments))
+		}
+	} else if len(arguments) != len(s.Params) {
+		return fmt.Errorf("Expected %s but got %d.", Arguments(len(s.Params)), len(arguments))
+	}
+
+	for i, argument := range arguments {
+		param := s.Params[min(i, len(s.Params)-1)]
+		if !param.Matches(argument) {
+			return fmt.Errorf("Cannot use %s as argument %d of type %s.", TypeName(argument), i+1, param)
+		}
+	}
+	return nil
+}
+
+// Arguments returns n followed by "argument",
+// made plural unless n is 1.
+func Arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// SignatureOf returns the signature of a function
+// declared with params and returnType, which is
+// nil if it doesn't return a value.
+func SignatureOf(params []FuncParam, returnType *TypeExpr) Signature {
+	signature := Signature{Return: ValueType{Kind: VoidKind}}
+	for _, param := range params {
+		signature.Params = append(signature.Params, TypeOf(param.Type))
+	}
+	if returnType != nil {
+		signature.Return = TypeOf(*returnType)
+	}
+	return signature
+}
+
+// TypeOf returns the runtime type of a type written
+// in source. The checker has made sure it exists.
+func TypeOf(typeExpr TypeExpr) ValueType {
+	switch typeExpr.Name.Type {
+	case INT_VAR:
+		return ValueType{Kind: IntKind}
+	case DOUBLE_VAR:
+		return ValueType{Kind: DoubleKind}
+	case STRING_VAR:
+		return ValueType{Kind: StringKind}
+	case BOOL_VAR:
+		return ValueType{Kind: BoolKind}
+	case LIST_VAR:
+		list := ValueType{Kind: ListKind}
+		if len(typeExpr.Args) == 1 {
+			elem := TypeOf(typeExpr.Args[0])
+			list.Elem = &elem
+		}
+		return list
+	case MAP_VAR:
+		m := ValueType{Kind: MapKind}
+		if len(typeExpr.Args) == 2 {
+			key, elem := TypeOf(typeExpr.Args[0]), TypeOf(typeExpr.Args[1])
+			m.Key, m.Elem = &key, &elem
+		}
+		return m
+	case IDENTIFIER:
+		if typeExpr.Name.Lexeme == "range" {
+			return ValueType{Kind: RangeKind}
+		}
+		return ValueType{Kind: StructKind, Name: typeExpr.Name.Lexeme}
+	case FUNC:
+		return ValueType{Kind: FuncKind}
+	}
+
+	return ValueType{Kind: AnyKind}
+}
@@ -0,0 +1,186 @@
+package models
+
+// Position is a place in source. Offset counts bytes
+// from the start of the source, and Column counts
+// characters from the start of the line, from 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the source from Start up to but
+// not including End. The zero Span means
+// a node that wasn't parsed from source.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// To returns the span from the start of s to the end of end.
+func (s Span) To(end Span) Span {
+	return Span{Start: s.Start, End: end.End}
+}
+
+// Width returns how many columns s covers. A span over
+// several lines is given a width that reaches past the
+// end of its first line.
+func (s Span) Width() int {
+	if s.End.Line == s.Start.Line {
+		return s.End.Column - s.Start.Column
+	}
+	return s.End.Offset - s.Start.Offset
+}
+
+func (t Token) Span() Span {
+	return Span{Start: Position{Offset: t.Offset, Line: t.Line, Column: t.Column}, End: t.End}
+}
+
+func (e AssignExpr) Span() Span {
+	return e.Name.Span().To(e.Value.Span())
+}
+
+func (e BinaryExpr) Span() Span {
+	return e.Left.Span().To(e.Right.Span())
+}
+
+func (e UnaryExpr) Span() Span {
+	return e.Operator.Span().To(e.Right.Span())
+}
+
+func (e LiteralExpr) Span() Span {
+	return e.Token.Span()
+}
+
+func (e GroupingExpr) Span() Span {
+	return e.LeftParen.Span().To(e.RightParen.Span())
+}
+
+func (e VarExpr) Span() Span {
+	return e.Name.Span()
+}
+
+func (e LogicExpr) Span() Span {
+	return e.Left.Span().To(e.Right.Span())
+}
+
+func (e CallExpr) Span() Span {
+	return e.Callee.Span().To(e.Paren.Span())
+}
+
+func (e ListExpr) Span() Span {
+	return e.Bracket.Span().To(e.RightBracket.Span())
+}
+
+func (e MapExpr) Span() Span {
+	return e.Brace.Span().To(e.RightBrace.Span())
+}
+
+func (e IndexExpr) Span() Span {
+	return e.Object.Span().To(e.RightBracket.Span())
+}
+
+func (e IndexSetExpr) Span() Span {
+	return e.Object.Span().To(e.Value.Span())
+}
+
+func (e GetExpr) Span() Span {
+	return e.Object.Span().To(e.Name.Span())
+}
+
+func (e SetExpr) Span() Span {
+	return e.Object.Span().To(e.Value.Span())
+}
+
+func (e StructExpr) Span() Span {
+	return e.Name.Span().To(e.RightBrace.Span())
+}
+
+func (e FuncExpr) Span() Span {
+	return e.Keyword.Span().To(e.RightBrace.Span())
+}
+
+func (e ErrorExpr) Span() Span {
+	return Span{}
+}
+
+func (t TypeExpr) Span() Span {
+	return t.Name.Span().To(t.Last.Span())
+}
+
+func (s ExprStmt) Span() Span {
+	return s.Expression.Span()
+}
+
+func (s VarStmt) Span() Span {
+	if s.Initializer != nil {
+		return s.Type.Span().To(s.Initializer.Span())
+	}
+	return s.Type.Span().To(s.Name.Span())
+}
+
+func (s BlockStmt) Span() Span {
+	if s.LeftBrace.Line != 0 {
+		return s.LeftBrace.Span().To(s.RightBrace.Span())
+	}
+
+	// A desugared block covers the statements in it.
+	if len(s.Statements) == 0 {
+		return Span{}
+	}
+	return s.Statements[0].Span().To(s.Statements[len(s.Statements)-1].Span())
+}
+
+func (s IfStmt) Span() Span {
+	if s.ElseBranch != nil {
+		return s.Keyword.Span().To(s.ElseBranch.Span())
+	}
+	return s.Keyword.Span().To(s.ThenBranch.Span())
+}
+
+func (s WhileStmt) Span() Span {
+	if s.Label != nil {
+		return s.Label.Span().To(s.Body.Span())
+	}
+	return s.Keyword.Span().To(s.Body.Span())
+}
+
+func (s ForEachStmt) Span() Span {
+	if s.Label != nil {
+		return s.Label.Span().To(s.Body.Span())
+	}
+	return s.Keyword.Span().To(s.Body.Span())
+}
+
+func (s FuncStmt) Span() Span {
+	return s.Keyword.Span().To(s.RightBrace.Span())
+}
+
+func (s ReturnStmt) Span() Span {
+	if s.Value != nil {
+		return s.Keyword.Span().To(s.Value.Span())
+	}
+	return s.Keyword.Span()
+}
+
+func (s BreakStmt) Span() Span {
+	if s.Label != nil {
+		return s.Keyword.Span().To(s.Label.Span())
+	}
+	return s.Keyword.Span()
+}
+
+func (s ContinueStmt) Span() Span {
+	if s.Label != nil {
+		return s.Keyword.Span().To(s.Label.Span())
+	}
+	return s.Keyword.Span()
+}
+
+func (s StructStmt) Span() Span {
+	return s.Keyword.Span().To(s.RightBrace.Span())
+}
+
+func (s ErrorStmt) Span() Span {
+	return Span{}
+}
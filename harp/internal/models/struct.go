@@ -0,0 +1,47 @@
+package models
+
+// Struct is a user-defined struct type
+// as it's known at runtime.
+type Struct struct {
+	Name   string
+	Fields []string
+	// Types holds the type of each field, in
+	// the same order as Fields.
+	Types []ValueType
+}
+
+// StructOf returns the runtime struct
+// type declared by stmt.
+func StructOf(stmt StructStmt) *Struct {
+	s := &Struct{Name: stmt.Name.Lexeme}
+	for _, field := range stmt.Fields {
+		s.Fields = append(s.Fields, field.Name.Lexeme)
+		s.Types = append(s.Types, TypeOf(field.Type))
+	}
+	return s
+}
+
+func (s *Struct) String() string {
+	return "<struct " + s.Name + ">"
+}
+
+// Instance is a value of a struct type. Like lists,
+// instances are shared by reference.
+type Instance struct {
+	Struct *Struct
+	Fields map[string]interface{}
+}
+
+// NewInstance returns an instance of s with
+// every field set to the zero value of its type.
+func NewInstance(s *Struct) *Instance {
+	instance := &Instance{Struct: s, Fields: map[string]interface{}{}}
+	for i, field := range s.Fields {
+		instance.Fields[field] = s.Types[i].Zero()
+	}
+	return instance
+}
+
+func (i *Instance) String() string {
+	return Format(i)
+}
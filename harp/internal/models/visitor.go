@@ -0,0 +1,189 @@
+package models
+
+// ExprVisitor is a pass over expressions, such as
+// type checking or evaluation. Each node type has its
+// own method, so adding a node type stops every pass
+// from compiling until it handles the new node.
+type ExprVisitor interface {
+	VisitAssignExpr(expr AssignExpr) (interface{}, error)
+	VisitBinaryExpr(expr BinaryExpr) (interface{}, error)
+	VisitUnaryExpr(expr UnaryExpr) (interface{}, error)
+	VisitLiteralExpr(expr LiteralExpr) (interface{}, error)
+	VisitGroupingExpr(expr GroupingExpr) (interface{}, error)
+	VisitVarExpr(expr VarExpr) (interface{}, error)
+	VisitLogicExpr(expr LogicExpr) (interface{}, error)
+	VisitCallExpr(expr CallExpr) (interface{}, error)
+	VisitListExpr(expr ListExpr) (interface{}, error)
+	VisitMapExpr(expr MapExpr) (interface{}, error)
+	VisitIndexExpr(expr IndexExpr) (interface{}, error)
+	VisitIndexSetExpr(expr IndexSetExpr) (interface{}, error)
+	VisitGetExpr(expr GetExpr) (interface{}, error)
+	VisitSetExpr(expr SetExpr) (interface{}, error)
+	VisitStructExpr(expr StructExpr) (interface{}, error)
+	VisitFuncExpr(expr FuncExpr) (interface{}, error)
+	VisitErrorExpr(expr ErrorExpr) (interface{}, error)
+}
+
+// StmtVisitor is a pass over statements, with a
+// method for each statement type like ExprVisitor.
+type StmtVisitor interface {
+	VisitExprStmt(stmt ExprStmt) (interface{}, error)
+	VisitVarStmt(stmt VarStmt) (interface{}, error)
+	VisitBlockStmt(stmt BlockStmt) (interface{}, error)
+	VisitIfStmt(stmt IfStmt) (interface{}, error)
+	VisitWhileStmt(stmt WhileStmt) (interface{}, error)
+	VisitForEachStmt(stmt ForEachStmt) (interface{}, error)
+	VisitFuncStmt(stmt FuncStmt) (interface{}, error)
+	VisitReturnStmt(stmt ReturnStmt) (interface{}, error)
+	VisitBreakStmt(stmt BreakStmt) (interface{}, error)
+	VisitContinueStmt(stmt ContinueStmt) (interface{}, error)
+	VisitStructStmt(stmt StructStmt) (interface{}, error)
+	VisitErrorStmt(stmt ErrorStmt) (interface{}, error)
+}
+
+func (e AssignExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitAssignExpr(e)
+}
+
+func (e BinaryExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitBinaryExpr(e)
+}
+
+func (e UnaryExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitUnaryExpr(e)
+}
+
+func (e LiteralExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitLiteralExpr(e)
+}
+
+func (e GroupingExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitGroupingExpr(e)
+}
+
+func (e VarExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitVarExpr(e)
+}
+
+func (e LogicExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitLogicExpr(e)
+}
+
+func (e CallExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitCallExpr(e)
+}
+
+func (e ListExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitListExpr(e)
+}
+
+func (e MapExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitMapExpr(e)
+}
+
+func (e IndexExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitIndexExpr(e)
+}
+
+func (e IndexSetExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitIndexSetExpr(e)
+}
+
+func (e GetExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitGetExpr(e)
+}
+
+func (e SetExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitSetExpr(e)
+}
+
+func (e StructExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitStructExpr(e)
+}
+
+func (e FuncExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitFuncExpr(e)
+}
+
+func (e ErrorExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitErrorExpr(e)
+}
+
+func (s ExprStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitExprStmt(s)
+}
+
+func (s VarStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitVarStmt(s)
+}
+
+func (s BlockStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitBlockStmt(s)
+}
+
+func (s IfStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitIfStmt(s)
+}
+
+func (s WhileStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitWhileStmt(s)
+}
+
+func (s ForEachStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitForEachStmt(s)
+}
+
+func (s FuncStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitFuncStmt(s)
+}
+
+func (s ReturnStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitReturnStmt(s)
+}
+
+func (s BreakStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitBreakStmt(s)
+}
+
+func (s ContinueStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitContinueStmt(s)
+}
+
+func (s StructStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitStructStmt(s)
+}
+
+func (s ErrorStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitErrorStmt(s)
+}
+
+func (AssignExpr) exprNode()   {}
+func (BinaryExpr) exprNode()   {}
+func (UnaryExpr) exprNode()    {}
+func (LiteralExpr) exprNode()  {}
+func (GroupingExpr) exprNode() {}
+func (VarExpr) exprNode()      {}
+func (LogicExpr) exprNode()    {}
+func (CallExpr) exprNode()     {}
+func (ListExpr) exprNode()     {}
+func (MapExpr) exprNode()      {}
+func (IndexExpr) exprNode()    {}
+func (IndexSetExpr) exprNode() {}
+func (GetExpr) exprNode()      {}
+func (SetExpr) exprNode()      {}
+func (StructExpr) exprNode()   {}
+func (FuncExpr) exprNode()     {}
+func (ErrorExpr) exprNode()    {}
+
+func (ExprStmt) stmtNode()     {}
+func (VarStmt) stmtNode()      {}
+func (BlockStmt) stmtNode()    {}
+func (IfStmt) stmtNode()       {}
+func (WhileStmt) stmtNode()    {}
+func (ForEachStmt) stmtNode()  {}
+func (FuncStmt) stmtNode()     {}
+func (ReturnStmt) stmtNode()   {}
+func (BreakStmt) stmtNode()    {}
+func (ContinueStmt) stmtNode() {}
+func (StructStmt) stmtNode()   {}
+func (ErrorStmt) stmtNode()    {}
@@ -1,19 +1,49 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
 
-var parseErrors []error
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+)
 
-func reportError(err error) {
-	parseErrors = append(parseErrors, err)
+func (p *Parser) reportError(err error) {
+	p.parseErrors = append(p.parseErrors, err)
 }
 
 type ParseError struct {
 	Line    int
 	Column  int
+	Length  int
 	Message string
+	// Opening is the bracket left unclosed,
+	// if the error is about one.
+	Opening *models.Token
 }
 
 func (e *ParseError) Error() string {
-	return fmt.Sprintf("[Line %d:%d] Error: %s", e.Line, e.Column, e.Message)
+	message := fmt.Sprintf("[Line %d:%d] Error: %s", e.Line, e.Column, e.Message)
+	if e.Opening != nil {
+		message += fmt.Sprintf("\n    [Line %d:%d] Note: '%s' is opened here.", e.Opening.Line, e.Opening.Column, e.Opening.Lexeme)
+	}
+	return message
+}
+
+func (e *ParseError) Diagnostic() *diagnostics.Diagnostic {
+	diagnostic := &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Line,
+		Column:   e.Column,
+		Length:   e.Length,
+		Message:  e.Message,
+	}
+	if e.Opening != nil {
+		diagnostic.Notes = append(diagnostic.Notes, diagnostics.Note{
+			Line:    e.Opening.Line,
+			Column:  e.Opening.Column,
+			Length:  e.Opening.Span().Width(),
+			Message: fmt.Sprintf("'%s' is opened here.", e.Opening.Lexeme),
+		})
+	}
+	return diagnostic
 }
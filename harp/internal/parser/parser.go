@@ -1,164 +1,621 @@
 package parser
 
 import (
-	"reflect"
+	"fmt"
 
 	"github.com/astraikis/harp/internal/models"
 )
 
-var tokens []models.Token
-var stmts []models.Stmt
-var current = 0
+// Parser turns tokens into a syntax tree. Each
+// Parser holds its own state, so separate
+// Parsers can run at the same time.
+type Parser struct {
+	tokens      []models.Token
+	stmts       []models.Stmt
+	current     int
+	parseErrors []error
+	// incrementAllowed is set while starting the
+	// expression of an expression statement or a
+	// for increment, the only places ++ and -- go.
+	incrementAllowed bool
+	// loops holds the labels of the loops around the
+	// statement being parsed, innermost last, with ""
+	// for loops without one.
+	loops []string
+	// synced is set once sync has run, and syncedAt
+	// is the token it stopped at.
+	synced   bool
+	syncedAt int
+}
+
+// typeKeywords are the tokens a type can start with.
+var typeKeywords = []models.TokenType{models.INT_VAR, models.DOUBLE_VAR, models.BOOL_VAR, models.STRING_VAR, models.LIST_VAR, models.MAP_VAR}
+
+// compoundOperators maps each compound assignment
+// operator to the arithmetic operator it applies.
+var compoundOperators = map[models.TokenType]models.TokenType{
+	models.PLUS_EQUAL:    models.PLUS,
+	models.MINUS_EQUAL:   models.MINUS,
+	models.STAR_EQUAL:    models.STAR,
+	models.SLASH_EQUAL:   models.SLASH,
+	models.PERCENT_EQUAL: models.PERCENT,
+	models.PLUS_PLUS:     models.PLUS,
+	models.MINUS_MINUS:   models.MINUS,
+}
+
+// NewParser returns a Parser for tokens.
+func NewParser(tokens []models.Token) *Parser {
+	return &Parser{tokens: tokens}
+}
 
 // Parse parses a list of tokens and returns
 // the corresponding list of stmts.
-func Parse(scannedTokens []models.Token) ([]models.Stmt, []error) {
-	tokens = scannedTokens
+func Parse(tokens []models.Token) ([]models.Stmt, []error) {
+	return NewParser(tokens).Parse()
+}
+
+// Parse parses the Parser's tokens and returns
+// the corresponding list of stmts.
+func (p *Parser) Parse() ([]models.Stmt, []error) {
 	for {
-		if isAtEnd() {
+		if p.isAtEnd() {
 			break
 		}
-		next := declaration()
-		stmts = append(stmts, next)
+		next := p.declaration()
+		p.stmts = append(p.stmts, next)
 	}
 
-	return stmts, parseErrors
+	return p.stmts, p.parseErrors
 }
 
-func declaration() models.Stmt {
-	if match([]models.TokenType{models.INT_VAR, models.DOUBLE_VAR, models.BOOL_VAR, models.STRING_VAR}) {
-		return varDeclaration()
+func (p *Parser) declaration() models.Stmt {
+	if p.match(typeKeywords) || p.matchMapType() {
+		return p.varDeclaration()
+	}
+	// A struct name followed by a variable name.
+	if p.check(models.IDENTIFIER) && p.peekAt(1).Type == models.IDENTIFIER {
+		p.advance()
+		return p.varDeclaration()
+	}
+	if p.match([]models.TokenType{models.FUNC}) {
+		// A function type starts a variable declaration. To call
+		// an anonymous function straight away, wrap it in parens.
+		if p.check(models.LeftParen) {
+			return p.varDeclaration()
+		}
+		return p.function()
 	}
-	if match([]models.TokenType{models.FUNC}) {
-		return function()
+	if p.match([]models.TokenType{models.STRUCT}) {
+		return p.structDeclaration()
 	}
 
-	return statement()
+	return p.statement()
 }
 
-func function() models.Stmt {
-	name, err := consume([]models.TokenType{models.IDENTIFIER}, "Expect function name.")
+func (p *Parser) structDeclaration() models.Stmt {
+	keyword := p.previous()
+	name, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect struct name.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
-	_, err = consume([]models.TokenType{models.LeftParen}, "Expect '(' after function name.")
+	_, err = p.consume([]models.TokenType{models.LeftBrace}, "Expect '{' after struct name.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
-	var parameters []models.FuncParam
-	if !check(models.RightParen) {
-		paramType, err := consume([]models.TokenType{models.STRING_VAR, models.INT_VAR, models.DOUBLE_VAR, models.BOOL_VAR}, "Expect parameter name.")
+	var fields []models.StructField
+	for {
+		if p.check(models.RightBrace) || p.isAtEnd() {
+			break
+		}
+
+		fieldType, err := p.parseType("Expect field type.")
 		if err != nil {
 			return models.ErrorStmt{}
 		}
 
-		paramName, err := consume([]models.TokenType{models.IDENTIFIER}, "Expect parameter name.")
+		fieldName, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect field name.")
 		if err != nil {
 			return models.ErrorStmt{}
 		}
 
-		parameters = append(parameters, models.FuncParam{Type: paramType.Type, Name: paramName.Lexeme})
+		_, err = p.consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after field.")
+		if err != nil {
+			return models.ErrorStmt{}
+		}
+
+		fields = append(fields, models.StructField{Type: fieldType, Name: *fieldName})
+	}
+
+	rightBrace, err := p.consume([]models.TokenType{models.RightBrace}, "Expect '}' after struct fields.")
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	return models.StructStmt{Keyword: keyword, Name: *name, Fields: fields, RightBrace: *rightBrace, Binding: &models.Binding{}}
+}
+
+func (p *Parser) function() models.Stmt {
+	keyword := p.previous()
+	name, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect function name.")
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	_, err = p.consume([]models.TokenType{models.LeftParen}, "Expect '(' after function name.")
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	parameters, returnType, err := p.signature()
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	_, _ = p.consume([]models.TokenType{models.LeftBrace}, "Expect '{' before function body.")
+
+	body := p.functionBody()
+	return models.FuncStmt{Keyword: keyword, Name: *name, Params: parameters, ReturnType: returnType, Body: body, RightBrace: p.previous(), Binding: &models.Binding{}}
+}
 
+// signature parses the parameters and optional return
+// type of a function after its '(' has been consumed.
+func (p *Parser) signature() ([]models.FuncParam, *models.TypeExpr, error) {
+	var parameters []models.FuncParam
+	if !p.check(models.RightParen) {
 		for {
-			if !match([]models.TokenType{models.COMMA}) {
-				break
+			paramType, err := p.parseType("Expect parameter type.")
+			if err != nil {
+				return nil, nil, err
 			}
 
-			paramType, err := consume([]models.TokenType{models.STRING_VAR, models.INT_VAR, models.DOUBLE_VAR, models.BOOL_VAR}, "Expect parameter name.")
+			paramName, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect parameter name.")
 			if err != nil {
-				return models.ErrorStmt{}
+				return nil, nil, err
 			}
 
-			paramName, err := consume([]models.TokenType{models.IDENTIFIER}, "Expect parameter name.")
-			if err != nil {
-				return models.ErrorStmt{}
+			parameters = append(parameters, models.FuncParam{Type: paramType, Name: *paramName})
+
+			if !p.match([]models.TokenType{models.COMMA}) {
+				break
 			}
+		}
+	}
 
-			parameters = append(parameters, models.FuncParam{Type: paramType.Type, Name: paramName.Lexeme})
+	_, _ = p.consume([]models.TokenType{models.RightParen}, "Expect ')' after function parameters.")
+
+	var returnType *models.TypeExpr
+	if p.startsReturnType() {
+		parsed, err := p.parseType("Expect return type.")
+		if err != nil {
+			return nil, nil, err
 		}
+		returnType = &parsed
 	}
 
-	_, _ = consume([]models.TokenType{models.RightParen}, "Expect ')' after function parameters.")
-	_, _ = consume([]models.TokenType{models.LeftBrace}, "Expect '{' before function body.")
+	return parameters, returnType, nil
+}
+
+// startsReturnType reports whether the next tokens are
+// a function's return type rather than what follows
+// the function, such as its body or a variable name.
+func (p *Parser) startsReturnType() bool {
+	for _, keyword := range typeKeywords {
+		if p.check(keyword) {
+			return true
+		}
+	}
+
+	switch p.peek().Type {
+	case models.FUNC:
+		return p.peekAt(1).Type == models.LeftParen
+	case models.IDENTIFIER:
+		next := p.peekAt(1).Type
+		return next == models.IDENTIFIER || next == models.LeftBrace || p.isMap(0)
+	}
+
+	return false
+}
 
-	body := block()
-	return models.FuncStmt{Name: *name, Params: parameters, Body: body}
+// isMap reports whether the token offset places after
+// the current one is 'map' followed by '<', which starts
+// a map type wherever only a type can go. The scanner
+// leaves map an identifier, so functions and variables
+// can still be called map.
+func (p *Parser) isMap(offset int) bool {
+	token := p.peekAt(offset)
+	return token.Type == models.IDENTIFIER && token.Lexeme == "map" && p.peekAt(offset+1).Type == models.LESS
 }
 
-func varDeclaration() models.Stmt {
-	name, err := consume([]models.TokenType{models.IDENTIFIER}, "Expect variable name.")
+// matchMapType consumes 'map' if it starts a statement
+// with a map type rather than an expression like map < 5,
+// which it does if a type and ',' follow its '<'.
+func (p *Parser) matchMapType() bool {
+	if !p.isMap(0) {
+		return false
+	}
+	end, ok := p.skipType(2)
+	if !ok || p.peekAt(end).Type != models.COMMA {
+		return false
+	}
+
+	p.advance()
+	return true
+}
+
+// skipType returns the offset just past the type starting
+// offset places after the current token, without consuming
+// anything, and reports whether there is a type there.
+func (p *Parser) skipType(offset int) (int, bool) {
+	switch token := p.peekAt(offset); token.Type {
+	case models.INT_VAR, models.DOUBLE_VAR, models.BOOL_VAR, models.STRING_VAR:
+		return offset + 1, true
+	case models.IDENTIFIER:
+		if !p.isMap(offset) {
+			return offset + 1, true
+		}
+		end, ok := p.skipType(offset + 2)
+		if !ok || p.peekAt(end).Type != models.COMMA {
+			return 0, false
+		}
+		end, ok = p.skipType(end + 1)
+		if !ok || p.peekAt(end).Type != models.GREATER {
+			return 0, false
+		}
+		return end + 1, true
+	case models.LIST_VAR:
+		if p.peekAt(offset+1).Type != models.LESS {
+			return 0, false
+		}
+		end, ok := p.skipType(offset + 2)
+		if !ok || p.peekAt(end).Type != models.GREATER {
+			return 0, false
+		}
+		return end + 1, true
+	case models.FUNC:
+		if p.peekAt(offset+1).Type != models.LeftParen {
+			return 0, false
+		}
+		end := offset + 2
+		if p.peekAt(end).Type != models.RightParen {
+			for {
+				var ok bool
+				end, ok = p.skipType(end)
+				if !ok {
+					return 0, false
+				}
+				if p.peekAt(end).Type != models.COMMA {
+					break
+				}
+				end++
+			}
+			if p.peekAt(end).Type != models.RightParen {
+				return 0, false
+			}
+		}
+		if returnEnd, ok := p.skipType(end + 1); ok {
+			return returnEnd, true
+		}
+		return end + 1, true
+	}
+
+	return 0, false
+}
+
+// parseType parses a type such as int, list<int>,
+// map<string, int>, func(int) bool or the name of a struct.
+func (p *Parser) parseType(message string) (models.TypeExpr, error) {
+	keyword, err := p.consume(append([]models.TokenType{models.IDENTIFIER, models.FUNC}, typeKeywords...), message)
+	if err != nil {
+		return models.TypeExpr{}, err
+	}
+
+	return p.finishType(*keyword)
+}
+
+// finishType parses the rest of a type
+// after its keyword has been consumed.
+func (p *Parser) finishType(keyword models.Token) (models.TypeExpr, error) {
+	if keyword.Type == models.IDENTIFIER && keyword.Lexeme == "map" && p.check(models.LESS) {
+		keyword.Type = models.MAP_VAR
+	}
+	typeExpr := models.TypeExpr{Name: keyword}
+
+	if keyword.Type == models.LIST_VAR {
+		_, err := p.consume([]models.TokenType{models.LESS}, "Expect '<' after 'list'.")
+		if err != nil {
+			return typeExpr, err
+		}
+
+		elemType, err := p.parseType("Expect list element type.")
+		if err != nil {
+			return typeExpr, err
+		}
+		typeExpr.Args = append(typeExpr.Args, elemType)
+
+		_, err = p.consume([]models.TokenType{models.GREATER}, "Expect '>' after list element type.")
+		if err != nil {
+			return typeExpr, err
+		}
+	}
+
+	if keyword.Type == models.MAP_VAR {
+		_, err := p.consume([]models.TokenType{models.LESS}, "Expect '<' after 'map'.")
+		if err != nil {
+			return typeExpr, err
+		}
+
+		keyType, err := p.parseType("Expect map key type.")
+		if err != nil {
+			return typeExpr, err
+		}
+		typeExpr.Args = append(typeExpr.Args, keyType)
+
+		_, err = p.consume([]models.TokenType{models.COMMA}, "Expect ',' after map key type.")
+		if err != nil {
+			return typeExpr, err
+		}
+
+		valueType, err := p.parseType("Expect map value type.")
+		if err != nil {
+			return typeExpr, err
+		}
+		typeExpr.Args = append(typeExpr.Args, valueType)
+
+		_, err = p.consume([]models.TokenType{models.GREATER}, "Expect '>' after map value type.")
+		if err != nil {
+			return typeExpr, err
+		}
+	}
+
+	if keyword.Type == models.FUNC {
+		_, err := p.consume([]models.TokenType{models.LeftParen}, "Expect '(' after 'func'.")
+		if err != nil {
+			return typeExpr, err
+		}
+
+		if !p.check(models.RightParen) {
+			for {
+				paramType, err := p.parseType("Expect parameter type.")
+				if err != nil {
+					return typeExpr, err
+				}
+				typeExpr.Args = append(typeExpr.Args, paramType)
+
+				if !p.match([]models.TokenType{models.COMMA}) {
+					break
+				}
+			}
+		}
+
+		_, err = p.consume([]models.TokenType{models.RightParen}, "Expect ')' after parameter types.")
+		if err != nil {
+			return typeExpr, err
+		}
+
+		if p.startsReturnType() {
+			returnType, err := p.parseType("Expect return type.")
+			if err != nil {
+				return typeExpr, err
+			}
+			typeExpr.Return = &returnType
+		}
+	}
+
+	typeExpr.Last = p.previous()
+	return typeExpr, nil
+}
+
+func (p *Parser) varDeclaration() models.Stmt {
+	varType, err := p.finishType(p.previous())
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	name, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect variable name.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
 	var initializer models.Expr
-	if match([]models.TokenType{models.EQUAL}) {
-		initializer = expression()
+	if p.match([]models.TokenType{models.EQUAL}) {
+		initializer = p.expression()
+	}
+
+	_, _ = p.consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after variable declaration.")
+	return models.VarStmt{Type: varType, Name: *name, Initializer: initializer, Binding: &models.Binding{}}
+}
+
+func (p *Parser) statement() models.Stmt {
+	if p.match([]models.TokenType{models.IF}) {
+		return p.ifStatement()
+	}
+	if p.match([]models.TokenType{models.LeftBrace}) {
+		leftBrace := p.previous()
+		statements := p.block()
+		return models.BlockStmt{LeftBrace: leftBrace, Statements: statements, RightBrace: p.previous()}
+	}
+	if p.check(models.IDENTIFIER) && p.peekAt(1).Type == models.COLON {
+		return p.labeledStatement()
+	}
+	if p.match([]models.TokenType{models.WHILE}) {
+		return p.whileStatement(nil)
+	}
+	if p.match([]models.TokenType{models.FOR}) {
+		return p.forStatement(nil)
+	}
+	if p.match([]models.TokenType{models.RETURN}) {
+		return p.returnStatement()
+	}
+	if p.match([]models.TokenType{models.BREAK, models.CONTINUE}) {
+		return p.jumpStatement()
+	}
+	return p.expressionStatement()
+}
+
+// labeledStatement parses a loop with a label,
+// like outer: for (...) {...}.
+func (p *Parser) labeledStatement() models.Stmt {
+	label := p.advance()
+	p.advance()
+
+	if p.inLoop(label.Lexeme) {
+		p.reportError(&ParseError{
+			Line:    label.Line,
+			Column:  label.Column,
+			Length:  label.Span().Width(),
+			Message: fmt.Sprintf("Label '%s' is already used by an enclosing loop.", label.Lexeme),
+		})
+	}
+
+	keyword, err := p.consume([]models.TokenType{models.WHILE, models.FOR}, "Expect 'while' or 'for' after label.")
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+	if keyword.Type == models.WHILE {
+		return p.whileStatement(&label)
+	}
+	return p.forStatement(&label)
+}
+
+// jumpStatement parses a break or continue statement.
+func (p *Parser) jumpStatement() models.Stmt {
+	keyword := p.previous()
+
+	var label *models.Token
+	if p.match([]models.TokenType{models.IDENTIFIER}) {
+		name := p.previous()
+		label = &name
+	}
+
+	_, err := p.consume([]models.TokenType{models.SEMICOLON}, fmt.Sprintf("Expect ';' after '%s'.", keyword.Lexeme))
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	if len(p.loops) == 0 {
+		p.reportError(&ParseError{
+			Line:    keyword.Line,
+			Column:  keyword.Column,
+			Length:  keyword.Span().Width(),
+			Message: fmt.Sprintf("Cannot use '%s' outside of a loop.", keyword.Lexeme),
+		})
+	} else if label != nil && !p.inLoop(label.Lexeme) {
+		p.reportError(&ParseError{
+			Line:    label.Line,
+			Column:  label.Column,
+			Length:  label.Span().Width(),
+			Message: fmt.Sprintf("No enclosing loop is labeled '%s'.", label.Lexeme),
+		})
 	}
 
-	_, _ = consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after variable declaration.")
-	return models.VarStmt{Name: *name, Initializer: initializer}
+	if keyword.Type == models.BREAK {
+		return models.BreakStmt{Keyword: keyword, Label: label}
+	}
+	return models.ContinueStmt{Keyword: keyword, Label: label}
 }
 
-func statement() models.Stmt {
-	if match([]models.TokenType{models.IF}) {
-		return ifStatement()
+// inLoop reports whether one of the loops around
+// the statement being parsed is labeled label.
+func (p *Parser) inLoop(label string) bool {
+	for _, enclosing := range p.loops {
+		if enclosing == label {
+			return true
+		}
 	}
-	if match([]models.TokenType{models.LeftBrace}) {
-		return models.BlockStmt{Statements: block()}
+	return false
+}
+
+// loopBody parses the body of a loop labeled label,
+// which may be nil.
+func (p *Parser) loopBody(label *models.Token) models.Stmt {
+	name := ""
+	if label != nil {
+		name = label.Lexeme
 	}
-	if match([]models.TokenType{models.WHILE}) {
-		return whileStatement()
+
+	p.loops = append(p.loops, name)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
+	return p.statement()
+}
+
+// functionBody parses the block of a function. Loops
+// around the function don't reach into it, so break
+// and continue inside it can't leave them.
+func (p *Parser) functionBody() []models.Stmt {
+	loops := p.loops
+	p.loops = nil
+	defer func() { p.loops = loops }()
+	return p.block()
+}
+
+func (p *Parser) returnStatement() models.Stmt {
+	keyword := p.previous()
+
+	var value models.Expr
+	if !p.check(models.SEMICOLON) {
+		value = p.expression()
 	}
-	if match([]models.TokenType{models.FOR}) {
-		return forStatement()
+
+	_, err := p.consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after return value.")
+	if err != nil {
+		return models.ErrorStmt{}
 	}
-	return expressionStatement()
+
+	return models.ReturnStmt{Keyword: keyword, Value: value}
 }
 
-func forStatement() models.Stmt {
-	_, err := consume([]models.TokenType{models.LeftParen}, "Expect '(' after for.")
+func (p *Parser) forStatement(label *models.Token) models.Stmt {
+	keyword := p.previous()
+	_, err := p.consume([]models.TokenType{models.LeftParen}, "Expect '(' after for.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
+	if p.isForEach() {
+		return p.forEachStatement(keyword, label)
+	}
+
 	var initializer models.Stmt
-	if match([]models.TokenType{models.INT_VAR, models.DOUBLE_VAR, models.BOOL_VAR, models.STRING_VAR}) {
-		initializer = varDeclaration()
+	if p.match([]models.TokenType{models.SEMICOLON}) {
+		initializer = nil
+	} else if p.match(typeKeywords) || p.matchMapType() {
+		initializer = p.varDeclaration()
+	} else if p.check(models.IDENTIFIER) && p.peekAt(1).Type == models.IDENTIFIER {
+		p.advance()
+		initializer = p.varDeclaration()
 	} else {
-		initializer = expressionStatement()
+		initializer = p.expressionStatement()
 	}
 
 	var condition models.Expr
-	if !check(models.SEMICOLON) {
-		condition = expression()
+	if !p.check(models.SEMICOLON) {
+		condition = p.expression()
 	} else {
 		condition = nil
 	}
 
-	_, _ = consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after loop condition.")
+	_, _ = p.consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after loop condition.")
 
 	var increment models.Expr
-	if !check(models.RightParen) {
-		increment = expression()
+	if !p.check(models.RightParen) {
+		p.incrementAllowed = true
+		increment = p.expression()
 	} else {
 		increment = nil
 	}
 
-	_, _ = consume([]models.TokenType{models.RightParen}, "Expect ')' after for clauses.")
+	_, _ = p.consume([]models.TokenType{models.RightParen}, "Expect ')' after for clauses.")
 
-	body := statement()
-
-	if increment != nil {
-		body = models.BlockStmt{Statements: []models.Stmt{body, models.ExprStmt{Expression: increment}}}
-	}
+	body := p.loopBody(label)
 
 	if condition == nil {
-		condition = models.LiteralExpr{Literal: true}
+		condition = models.LiteralExpr{Token: keyword, Literal: true}
 	}
-	body = models.WhileStmt{Condition: condition, Body: body}
+	// The increment is kept apart from the body so
+	// that continue doesn't skip it.
+	body = models.WhileStmt{Keyword: keyword, Condition: condition, Body: body, Increment: increment, Label: label}
 
 	if initializer != nil {
 		body = models.BlockStmt{Statements: []models.Stmt{initializer, body}}
@@ -167,56 +624,131 @@ func forStatement() models.Stmt {
 	return body
 }
 
-func whileStatement() models.Stmt {
-	_, err := consume([]models.TokenType{models.LeftParen}, "Expect '(' after while.")
+// isForEach reports whether the for loop whose '(' was
+// just consumed is a for-each loop, which has 'in' before
+// the end of its first clause.
+func (p *Parser) isForEach() bool {
+	depth := 0
+	for i := 0; ; i++ {
+		switch p.peekAt(i).Type {
+		case models.IN:
+			return depth == 0
+		case models.LeftParen:
+			depth++
+		case models.RightParen:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case models.SEMICOLON, models.EOF:
+			return false
+		}
+	}
+}
+
+// forEachStatement parses the rest of a loop
+// like for (string k, int v in m) {...}.
+func (p *Parser) forEachStatement(keyword models.Token, label *models.Token) models.Stmt {
+	var vars []models.LoopVar
+	missingType := false
+	for {
+		// A name straight before 'in' or ',' is missing its
+		// type. The rest of the loop is still parsed, so the
+		// break and continue in its body aren't reported.
+		if p.check(models.IDENTIFIER) && (p.peekAt(1).Type == models.IN || p.peekAt(1).Type == models.COMMA) {
+			p.errorAtCurrent(fmt.Sprintf("Expect loop variable type before '%s'.", p.peek().Lexeme))
+			missingType = true
+			vars = append(vars, models.LoopVar{Name: p.advance()})
+			if len(vars) == 2 || !p.match([]models.TokenType{models.COMMA}) {
+				break
+			}
+			continue
+		}
+
+		varType, err := p.parseType("Expect loop variable type.")
+		if err != nil {
+			return models.ErrorStmt{}
+		}
+		name, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect loop variable name.")
+		if err != nil {
+			return models.ErrorStmt{}
+		}
+		vars = append(vars, models.LoopVar{Type: varType, Name: *name})
+
+		if len(vars) == 2 || !p.match([]models.TokenType{models.COMMA}) {
+			break
+		}
+	}
+
+	in, err := p.consume([]models.TokenType{models.IN}, "Expect 'in' after loop variables.")
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+	iterable := p.expression()
+	_, err = p.consume([]models.TokenType{models.RightParen}, "Expect ')' after for-each clause.")
+	if err != nil {
+		return models.ErrorStmt{}
+	}
+
+	body := p.loopBody(label)
+	if missingType {
+		return models.ErrorStmt{}
+	}
+	return models.ForEachStmt{Keyword: keyword, Vars: vars, In: *in, Iterable: iterable, Body: body, Label: label}
+}
+
+func (p *Parser) whileStatement(label *models.Token) models.Stmt {
+	keyword := p.previous()
+	_, err := p.consume([]models.TokenType{models.LeftParen}, "Expect '(' after while.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
-	condition := expression()
-	_, err = consume([]models.TokenType{models.RightParen}, "Expect ')' after while condition.")
+	condition := p.expression()
+	_, err = p.consume([]models.TokenType{models.RightParen}, "Expect ')' after while condition.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
-	body := statement()
+	body := p.loopBody(label)
 
-	return models.WhileStmt{Condition: condition, Body: body}
+	return models.WhileStmt{Keyword: keyword, Condition: condition, Body: body, Label: label}
 }
 
-func ifStatement() models.Stmt {
-	_, err := consume([]models.TokenType{models.LeftParen}, "Expect '(' after 'if'.")
+func (p *Parser) ifStatement() models.Stmt {
+	keyword := p.previous()
+	_, err := p.consume([]models.TokenType{models.LeftParen}, "Expect '(' after 'if'.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
-	condition := expression()
-	_, err = consume([]models.TokenType{models.RightParen}, "Expect ')' after 'if'.")
+	condition := p.expression()
+	_, err = p.consume([]models.TokenType{models.RightParen}, "Expect ')' after 'if'.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
 
-	thenBranch := statement()
+	thenBranch := p.statement()
 	var elseBranch models.Stmt
-	if match([]models.TokenType{models.ELSE}) {
-		elseBranch = statement()
+	if p.match([]models.TokenType{models.ELSE}) {
+		elseBranch = p.statement()
 	}
 
-	return models.IfStmt{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
+	return models.IfStmt{Keyword: keyword, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
 }
 
-func block() []models.Stmt {
+func (p *Parser) block() []models.Stmt {
 	var blockStmts = []models.Stmt{}
 
 	for {
-		if check(models.RightBrace) || isAtEnd() {
+		if p.check(models.RightBrace) || p.isAtEnd() {
 			break
 		}
 
-		blockStmts = append(blockStmts, declaration())
+		blockStmts = append(blockStmts, p.declaration())
 	}
 
-	_, err := consume([]models.TokenType{models.RightBrace}, "Expect '}' after block.")
+	_, err := p.consume([]models.TokenType{models.RightBrace}, "Expect '}' after block.")
 	if err != nil {
 		return []models.Stmt{models.ErrorStmt{}}
 	}
@@ -224,10 +756,11 @@ func block() []models.Stmt {
 	return blockStmts
 }
 
-func expressionStatement() models.Stmt {
-	expr := expression()
+func (p *Parser) expressionStatement() models.Stmt {
+	p.incrementAllowed = true
+	expr := p.expression()
 
-	_, err := consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after expression.")
+	_, err := p.consume([]models.TokenType{models.SEMICOLON}, "Expect ';' after expression.")
 	if err != nil {
 		return models.ErrorStmt{}
 	}
@@ -235,168 +768,228 @@ func expressionStatement() models.Stmt {
 	return models.ExprStmt{Expression: expr}
 }
 
-func expression() models.Expr {
-	return assignment()
+func (p *Parser) expression() models.Expr {
+	return p.assignment()
 }
 
-func assignment() models.Expr {
-	expr := or()
-
-	if match([]models.TokenType{models.EQUAL}) {
-		equals := previous()
-		value := assignment()
-
-		if reflect.TypeOf(expr).String() == "models.VarExpr" {
-			name := expr.(models.VarExpr).Name
-			return models.AssignExpr{Name: name, Value: value}
+func (p *Parser) assignment() models.Expr {
+	// Expressions nested in this one can't
+	// be followed by ++ or --.
+	incrementAllowed := p.incrementAllowed
+	p.incrementAllowed = false
+
+	expr := p.or()
+
+	if p.match([]models.TokenType{models.PLUS_PLUS, models.MINUS_MINUS}) {
+		operator := p.previous()
+		if !incrementAllowed {
+			p.reportError(&ParseError{
+				Line:    operator.Line,
+				Column:  operator.Column,
+				Length:  operator.Span().Width(),
+				Message: fmt.Sprintf("'%s' can only be used as a statement.", operator.Lexeme),
+			})
 		}
 
-		// Error
-		if equals == value {
-		}
+		// x++ is x += 1.
+		return p.assignTo(expr, operator, models.LiteralExpr{Token: operator, Literal: int64(1)})
+	}
+
+	if p.match([]models.TokenType{models.EQUAL, models.PLUS_EQUAL, models.MINUS_EQUAL, models.STAR_EQUAL, models.SLASH_EQUAL, models.PERCENT_EQUAL}) {
+		equals := p.previous()
+		value := p.assignment()
+		return p.assignTo(expr, equals, value)
 	}
 
 	return expr
 }
 
-func or() models.Expr {
-	expr := and()
+// assignTo returns the assignment of value to target by
+// equals, which is '=' or a compound assignment operator.
+func (p *Parser) assignTo(target models.Expr, equals models.Token, value models.Expr) models.Expr {
+	var operator *models.Token
+	if arithmetic, ok := compoundOperators[equals.Type]; ok {
+		lowered := equals
+		lowered.Type = arithmetic
+		operator = &lowered
+	}
+
+	switch target := target.(type) {
+	case models.VarExpr:
+		return models.AssignExpr{Name: target.Name, Value: value, Operator: operator, Binding: &models.Binding{}}
+	case models.GetExpr:
+		return models.SetExpr{Object: target.Object, Name: target.Name, Value: value, Operator: operator}
+	case models.IndexExpr:
+		return models.IndexSetExpr{Object: target.Object, Bracket: target.Bracket, Index: target.Index, Value: value, Operator: operator}
+	}
+
+	p.reportError(&ParseError{
+		Line:    equals.Line,
+		Column:  equals.Column,
+		Length:  equals.Span().Width(),
+		Message: "Invalid assignment target.",
+	})
+	return target
+}
+
+func (p *Parser) or() models.Expr {
+	expr := p.and()
 
 	for {
-		if !match([]models.TokenType{models.OR}) {
+		if !p.match([]models.TokenType{models.OR}) {
 			break
 		}
 
-		operator := previous()
-		right := and()
+		operator := p.previous()
+		right := p.and()
 		expr = models.LogicExpr{Left: expr, Operator: operator, Right: right}
 	}
 
 	return expr
 }
 
-func and() models.Expr {
-	expr := equality()
+func (p *Parser) and() models.Expr {
+	expr := p.equality()
 
 	for {
-		if !match([]models.TokenType{models.AND}) {
+		if !p.match([]models.TokenType{models.AND}) {
 			break
 		}
 
-		operator := previous()
-		right := equality()
+		operator := p.previous()
+		right := p.equality()
 		expr = models.LogicExpr{Left: expr, Operator: operator, Right: right}
 	}
 
 	return expr
 }
 
-func equality() models.Expr {
-	parsed := comparison()
+func (p *Parser) equality() models.Expr {
+	parsed := p.comparison()
 
 	for {
-		if !match([]models.TokenType{models.BANG_EQUAL, models.EQUAL_EQUAL}) {
+		if !p.match([]models.TokenType{models.BANG_EQUAL, models.EQUAL_EQUAL}) {
 			break
 		}
 
-		operator := previous()
-		right := comparison()
+		operator := p.previous()
+		right := p.comparison()
 		parsed = models.BinaryExpr{Left: parsed, Operator: operator, Right: right}
 	}
 
 	return parsed
 }
 
-func comparison() models.Expr {
-	parsed := term()
+func (p *Parser) comparison() models.Expr {
+	parsed := p.term()
 
 	for {
-		if !match([]models.TokenType{models.GREATER, models.GREATER_EQUAL, models.LESS, models.LESS_EQUAL}) {
+		if !p.match([]models.TokenType{models.GREATER, models.GREATER_EQUAL, models.LESS, models.LESS_EQUAL}) {
 			break
 		}
 
-		operator := previous()
-		right := term()
+		operator := p.previous()
+		right := p.term()
 		parsed = models.BinaryExpr{Left: parsed, Operator: operator, Right: right}
 	}
 
 	return parsed
 }
 
-func term() models.Expr {
-	parsed := factor()
+func (p *Parser) term() models.Expr {
+	parsed := p.factor()
 
 	for {
-		if !match([]models.TokenType{models.MINUS, models.PLUS}) {
+		if !p.match([]models.TokenType{models.MINUS, models.PLUS}) {
 			break
 		}
 
-		operator := previous()
-		right := factor()
+		operator := p.previous()
+		right := p.factor()
 		parsed = models.BinaryExpr{Left: parsed, Operator: operator, Right: right}
 	}
 
 	return parsed
 }
 
-func factor() models.Expr {
-	parsed := unary()
+func (p *Parser) factor() models.Expr {
+	parsed := p.unary()
 
 	for {
-		if !match([]models.TokenType{models.STAR, models.SLASH}) {
+		if !p.match([]models.TokenType{models.STAR, models.SLASH, models.PERCENT}) {
 			break
 		}
 
-		operator := previous()
-		right := unary()
+		operator := p.previous()
+		right := p.unary()
 		parsed = models.BinaryExpr{Left: parsed, Operator: operator, Right: right}
 	}
 
 	return parsed
 }
 
-func unary() models.Expr {
-	if match([]models.TokenType{models.BANG, models.MINUS}) {
-		operator := previous()
-		right := unary()
+func (p *Parser) unary() models.Expr {
+	if p.match([]models.TokenType{models.BANG, models.MINUS}) {
+		operator := p.previous()
+		right := p.unary()
 		return models.UnaryExpr{Operator: operator, Right: right}
 	}
 
-	return call()
+	return p.call()
 }
 
-func call() models.Expr {
-	expr := primary()
+func (p *Parser) call() models.Expr {
+	expr := p.primary()
 
 	for {
-		if !match([]models.TokenType{models.LeftParen}) {
+		if p.match([]models.TokenType{models.LeftParen}) {
+			expr = p.finishCall(expr)
+		} else if p.match([]models.TokenType{models.LEFT_SQUARE}) {
+			expr = p.finishIndex(expr)
+		} else if p.match([]models.TokenType{models.DOT}) {
+			name, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect field name after '.'.")
+			if err != nil {
+				return models.ErrorExpr{}
+			}
+			expr = models.GetExpr{Object: expr, Name: *name}
+		} else {
 			break
 		}
-
-		expr = finishCall(expr)
 	}
 
 	return expr
 }
 
-func finishCall(callee models.Expr) models.Expr {
+func (p *Parser) finishIndex(object models.Expr) models.Expr {
+	bracket := p.previous()
+	index := p.expression()
+
+	rightBracket, err := p.consume([]models.TokenType{models.RIGHT_SQUARE}, "Expect ']' after index.")
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	return models.IndexExpr{Object: object, Bracket: bracket, Index: index, RightBracket: *rightBracket}
+}
+
+func (p *Parser) finishCall(callee models.Expr) models.Expr {
 	var arguments []models.Expr
 
-	if !check(models.RightParen) {
-		arguments = append(arguments, expression())
+	if !p.check(models.RightParen) {
+		arguments = append(arguments, p.expression())
 
 		for {
-			if !match([]models.TokenType{models.COMMA}) {
+			if !p.match([]models.TokenType{models.COMMA}) {
 				break
 			}
-			if len(arguments) >= 255 {
-				// Error
+			if len(arguments) == 255 {
+				p.errorAtCurrent("Cannot have more than 255 arguments.")
 			}
-			arguments = append(arguments, expression())
+			arguments = append(arguments, p.expression())
 		}
 	}
 
-	paren, err := consume([]models.TokenType{models.RightParen}, "Expect ')' after arguments.")
+	paren, err := p.consume([]models.TokenType{models.RightParen}, "Expect ')' after arguments.")
 	if err != nil {
 		return models.ErrorExpr{}
 	}
@@ -404,71 +997,239 @@ func finishCall(callee models.Expr) models.Expr {
 	return models.CallExpr{Callee: callee, Paren: *paren, Arguments: arguments}
 }
 
-func primary() models.Expr {
-	if match([]models.TokenType{models.TRUE}) {
-		return models.LiteralExpr{Literal: true}
+func (p *Parser) primary() models.Expr {
+	if p.match([]models.TokenType{models.TRUE}) {
+		return models.LiteralExpr{Token: p.previous(), Literal: true}
 	}
-	if match([]models.TokenType{models.FALSE}) {
-		return models.LiteralExpr{Literal: false}
+	if p.match([]models.TokenType{models.FALSE}) {
+		return models.LiteralExpr{Token: p.previous(), Literal: false}
 	}
-	if match([]models.TokenType{models.NULL}) {
-		return models.LiteralExpr{Literal: nil}
+	if p.match([]models.TokenType{models.NULL}) {
+		return models.LiteralExpr{Token: p.previous(), Literal: nil}
 	}
-	if match([]models.TokenType{models.INT, models.DOUBLE, models.STRING}) {
-		return models.LiteralExpr{Literal: previous().Literal}
+	if p.match([]models.TokenType{models.INT, models.DOUBLE, models.STRING}) {
+		return models.LiteralExpr{Token: p.previous(), Literal: p.previous().Literal}
 	}
-	if match([]models.TokenType{models.IDENTIFIER}) {
-		return models.VarExpr{Name: previous()}
+	if p.match([]models.TokenType{models.IDENTIFIER}) {
+		if p.isStructLiteral() {
+			return p.structLiteral()
+		}
+		return models.VarExpr{Name: p.previous(), Binding: &models.Binding{}}
 	}
-	if match([]models.TokenType{models.LeftParen}) {
-		inner := expression()
-		_, err := consume([]models.TokenType{models.RightParen}, "Expect ')' after expression.")
-		if err != nil {
-			sync()
-			// Error
+	if p.match([]models.TokenType{models.LEFT_SQUARE}) {
+		return p.list()
+	}
+	// A brace only starts an expression where a statement
+	// can't be, so it's a map literal rather than a block.
+	if p.match([]models.TokenType{models.LeftBrace}) {
+		return p.mapLiteral()
+	}
+	if p.match([]models.TokenType{models.FUNC}) {
+		return p.funcExpr()
+	}
+	if p.match([]models.TokenType{models.LeftParen}) {
+		leftParen := p.previous()
+		inner := p.expression()
+		if !p.match([]models.TokenType{models.RightParen}) {
+			// The token is left for the enclosing statement,
+			// which usually ends there, so only this error
+			// is reported.
+			p.reportError(&ParseError{
+				Line:    p.peek().Line,
+				Column:  p.peek().Column,
+				Length:  p.peek().Span().Width(),
+				Message: "Unclosed '('.",
+				Opening: &leftParen,
+			})
+			return models.ErrorExpr{}
 		}
-		return models.GroupingExpr{Expression: inner}
+		return models.GroupingExpr{LeftParen: leftParen, Expression: inner, RightParen: p.previous()}
 	}
 
-	return models.LiteralExpr{Literal: false}
+	// Leave the token for the enclosing statement to
+	// sync past, so it isn't reported a second time.
+	p.errorAtCurrent("Expect expression.")
+	return models.ErrorExpr{}
+}
+
+// isStructLiteral reports whether the identifier just
+// consumed starts a struct literal like Point{x: 1}.
+func (p *Parser) isStructLiteral() bool {
+	if !p.check(models.LeftBrace) {
+		return false
+	}
+
+	next := p.peekAt(1).Type
+	return next == models.RightBrace || (next == models.IDENTIFIER && p.peekAt(2).Type == models.COLON)
+}
+
+func (p *Parser) structLiteral() models.Expr {
+	name := p.previous()
+	p.advance()
+
+	var fields []models.FieldInit
+	if !p.check(models.RightBrace) {
+		for {
+			fieldName, err := p.consume([]models.TokenType{models.IDENTIFIER}, "Expect field name.")
+			if err != nil {
+				return models.ErrorExpr{}
+			}
+
+			_, err = p.consume([]models.TokenType{models.COLON}, "Expect ':' after field name.")
+			if err != nil {
+				return models.ErrorExpr{}
+			}
+
+			fields = append(fields, models.FieldInit{Name: *fieldName, Value: p.expression()})
+
+			if !p.match([]models.TokenType{models.COMMA}) {
+				break
+			}
+		}
+	}
+
+	rightBrace, err := p.consume([]models.TokenType{models.RightBrace}, "Expect '}' after struct fields.")
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	return models.StructExpr{Name: name, Fields: fields, RightBrace: *rightBrace, Binding: &models.Binding{}}
+}
+
+func (p *Parser) funcExpr() models.Expr {
+	keyword := p.previous()
+
+	_, err := p.consume([]models.TokenType{models.LeftParen}, "Expect '(' after 'func'.")
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	parameters, returnType, err := p.signature()
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	_, err = p.consume([]models.TokenType{models.LeftBrace}, "Expect '{' before function body.")
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	body := p.functionBody()
+	return models.FuncExpr{Keyword: keyword, Params: parameters, ReturnType: returnType, Body: body, RightBrace: p.previous()}
+}
+
+func (p *Parser) list() models.Expr {
+	bracket := p.previous()
+
+	var elements []models.Expr
+	if !p.check(models.RIGHT_SQUARE) {
+		for {
+			elements = append(elements, p.expression())
+
+			if !p.match([]models.TokenType{models.COMMA}) {
+				break
+			}
+		}
+	}
+
+	rightBracket, err := p.consume([]models.TokenType{models.RIGHT_SQUARE}, "Expect ']' after list elements.")
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	return models.ListExpr{Bracket: bracket, Elements: elements, RightBracket: *rightBracket}
+}
+
+func (p *Parser) mapLiteral() models.Expr {
+	brace := p.previous()
+
+	var entries []models.MapEntry
+	if !p.check(models.RightBrace) {
+		for {
+			key := p.expression()
+
+			_, err := p.consume([]models.TokenType{models.COLON}, "Expect ':' after map key.")
+			if err != nil {
+				return models.ErrorExpr{}
+			}
+
+			entries = append(entries, models.MapEntry{Key: key, Value: p.expression()})
+
+			if !p.match([]models.TokenType{models.COMMA}) {
+				break
+			}
+		}
+	}
+
+	rightBrace, err := p.consume([]models.TokenType{models.RightBrace}, "Expect '}' after map entries.")
+	if err != nil {
+		return models.ErrorExpr{}
+	}
+
+	return models.MapExpr{Brace: brace, Entries: entries, RightBrace: *rightBrace}
 }
 
 // advance returns the next token.
-func advance() models.Token {
-	if !isAtEnd() {
-		current += 1
+func (p *Parser) advance() models.Token {
+	if !p.isAtEnd() {
+		p.current += 1
 	}
 
-	return previous()
+	return p.previous()
 }
 
 // consume checks if expected is equal to the next token's type
 // consumes it if it does.
-func consume(expectedTypes []models.TokenType, message string) (*models.Token, error) {
+func (p *Parser) consume(expectedTypes []models.TokenType, message string) (*models.Token, error) {
 	for _, expected := range expectedTypes {
-		if expected == peek().Type {
-			token := advance()
+		if expected == p.peek().Type {
+			token := p.advance()
 			return &token, nil
 		}
 	}
 
+	// An error inside the statement already skipped to
+	// here, so this token was never the statement's. That
+	// only excuses it once: if nothing takes the token, the
+	// next error here syncs past it so parsing moves on.
+	if p.synced && p.syncedAt == p.current {
+		p.synced = false
+		return nil, &ParseError{Line: p.peek().Line, Column: p.peek().Column, Length: p.peek().Span().Width(), Message: message}
+	}
+
+	err := p.errorAtCurrent(message)
+	p.sync()
+
+	return nil, err
+}
+
+// errorAtCurrent reports message at the current token
+// and returns the error. Only the first error at a
+// token is reported, since any after it are caused
+// by the same mistake.
+func (p *Parser) errorAtCurrent(message string) error {
 	err := &ParseError{
-		Line:    peek().Line,
-		Column:  peek().Column,
+		Line:    p.peek().Line,
+		Column:  p.peek().Column,
+		Length:  p.peek().Span().Width(),
 		Message: message,
 	}
-	reportError(err)
-	sync()
 
-	return nil, err
+	if len(p.parseErrors) > 0 {
+		if last, ok := p.parseErrors[len(p.parseErrors)-1].(*ParseError); ok && last.Line == err.Line && last.Column == err.Column {
+			return err
+		}
+	}
+	p.reportError(err)
+	return err
 }
 
 // match reports whether the current token
 // matches tokenType and consumes it if it does.
-func match(expectedTypes []models.TokenType) bool {
+func (p *Parser) match(expectedTypes []models.TokenType) bool {
 	for _, expected := range expectedTypes {
-		if check(expected) {
-			advance()
+		if p.check(expected) {
+			p.advance()
 			return true
 		}
 	}
@@ -478,42 +1239,52 @@ func match(expectedTypes []models.TokenType) bool {
 
 // check reports whether the current token
 // matches tokenType, but does not consume it.
-func check(tokenType models.TokenType) bool {
-	if isAtEnd() {
+func (p *Parser) check(tokenType models.TokenType) bool {
+	if p.isAtEnd() {
 		return false
 	}
 
-	return peek().Type == tokenType
+	return p.peek().Type == tokenType
 }
 
 // peek returns the current token.
-func peek() models.Token {
-	return tokens[current]
+func (p *Parser) peek() models.Token {
+	return p.tokens[p.current]
+}
+
+// peekAt returns the token offset places after the
+// current one, or the last token if that's past the end.
+func (p *Parser) peekAt(offset int) models.Token {
+	if p.current+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.current+offset]
 }
 
 // previous returns the previous token.
-func previous() models.Token {
-	return tokens[current-1]
+func (p *Parser) previous() models.Token {
+	return p.tokens[p.current-1]
 }
 
 // isAtEnd() reports whether we're at the end of tokens.
-func isAtEnd() bool {
-	return current >= len(tokens) || peek().Type == models.EOF
+func (p *Parser) isAtEnd() bool {
+	return p.current >= len(p.tokens) || p.peek().Type == models.EOF
 }
 
-func sync() {
-	advance()
+func (p *Parser) sync() {
+	p.advance()
+	defer func() { p.synced, p.syncedAt = true, p.current }()
 
 	for {
-		if isAtEnd() {
+		if p.isAtEnd() {
 			return
 		}
 
-		if previous().Type == models.SEMICOLON {
+		if p.previous().Type == models.SEMICOLON {
 			return
 		}
 
-		switch peek().Type {
+		switch p.peek().Type {
 		case models.FUNC:
 		case models.INT_VAR:
 		case models.DOUBLE_VAR:
@@ -526,6 +1297,6 @@ func sync() {
 			return
 		}
 
-		advance()
+		p.advance()
 	}
 }
@@ -0,0 +1,153 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+// parseTests pairs sources with the exact errors
+// the parser reports for them, in order.
+var parseTests = []struct {
+	name   string
+	source string
+	want   []string
+}{
+	{
+		name:   "missing operand in a group",
+		source: `int x = (1 + ;`,
+		want: []string{
+			"[Line 1:14] Error: Expect expression.",
+			"[Line 1:14] Error: Unclosed '('.\n    [Line 1:9] Note: '(' is opened here.",
+		},
+	},
+	{
+		name:   "unclosed group",
+		source: `int x = (1 + 2;`,
+		want:   []string{"[Line 1:15] Error: Unclosed '('.\n    [Line 1:9] Note: '(' is opened here."},
+	},
+	{
+		name: "unclosed group in a call",
+		source: `print((1 + 2 3);
+print(2);`,
+		want: []string{"[Line 1:14] Error: Unclosed '('.\n    [Line 1:7] Note: '(' is opened here."},
+	},
+	{
+		name:   "unclosed call",
+		source: `print((1);`,
+		want:   []string{"[Line 1:10] Error: Expect ')' after arguments."},
+	},
+	{
+		name: "for-each variable without a type",
+		source: `list<int> xs = [1];
+outer: for (x in xs) {
+    if (x == 1) {
+        break outer;
+    }
+    continue;
+}`,
+		want: []string{"[Line 2:13] Error: Expect loop variable type before 'x'."},
+	},
+	{
+		name:   "second for-each variable without a type",
+		source: `for (int i, v in [1]) { break; }`,
+		want:   []string{"[Line 1:13] Error: Expect loop variable type before 'v'."},
+	},
+	{
+		name: "for loop without an initializer",
+		source: `int i = 0;
+for (; i < 3; i = i + 1) {
+    print(i);
+}`,
+	},
+	{
+		name:   "for loop with no clauses",
+		source: `for (;;) { break; }`,
+	},
+	{
+		name: "map compared with <",
+		source: `int map = 3;
+print(map < 5, map<4);
+map < 5;
+for (map = 0; map < 3; map++) {}`,
+	},
+	{
+		name: "map types",
+		source: `map<string, list<int>> m = {};
+map <map<int, bool>, func(int) int> nested;
+for (map<string, int> n = {}; len(n) < 1; n["a"] = 1) {}
+func keyed(map<int, int> m) map<int, int> {
+    return m;
+}`,
+	},
+	{
+		name:   "too many arguments",
+		source: "print(" + strings.Repeat("1, ", 255) + "1);",
+		want:   []string{"[Line 1:772] Error: Cannot have more than 255 arguments."},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, errs := scanner.Scan(test.source)
+			if len(errs) > 0 {
+				t.Fatalf("scan: %v", errs)
+			}
+			_, errs = parser.Parse(tokens)
+
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d errors, want %d\ngot:  %q\nwant: %q", len(got), len(test.want), got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("error %d = %q, want %q", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestRecoveryFinishes checks that the parser moves past
+// tokens that recovery stops at but no statement can start
+// with, rather than reporting them over and over.
+func TestRecoveryFinishes(t *testing.T) {
+	sources := []string{
+		`print(1 2); }`,
+		`if (true) func f() { print(1); }`,
+		`return 1 2; }`,
+		`print(a.); }`,
+		`x = func; }`,
+		`{ print(1 2); } }`,
+	}
+
+	for _, source := range sources {
+		t.Run(source, func(t *testing.T) {
+			tokens, errs := scanner.Scan(source)
+			if len(errs) > 0 {
+				t.Fatalf("scan: %v", errs)
+			}
+
+			done := make(chan []error, 1)
+			go func() {
+				_, errs := parser.Parse(tokens)
+				done <- errs
+			}()
+			select {
+			case errs := <-done:
+				if len(errs) == 0 {
+					t.Error("got no errors")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("parsing did not finish")
+			}
+		})
+	}
+}
@@ -2,98 +2,322 @@ package parser
 
 import (
 	"fmt"
-	"reflect"
+	"strings"
 
 	"github.com/astraikis/harp/internal/models"
 )
 
+// PrintStatements prints the syntax tree of parsedStmts,
+// one node per line with its children indented under it.
 func PrintStatements(parsedStmts []models.Stmt) {
+	p := &printer{}
 	for _, stmt := range parsedStmts {
-		printStatement(stmt, 0)
+		p.printStmt(stmt)
 	}
 }
 
-func printStatement(stmt models.Expr, depth int) {
-	switch reflect.TypeOf(stmt).String() {
-	case "models.BinaryExpr":
-		be := stmt.(models.BinaryExpr)
-		for i := 0; i < depth; i++ {
-			fmt.Printf("   ")
-		}
-		fmt.Printf("Binary expression {\n")
+// printer prints each node it visits at depth.
+type printer struct {
+	depth int
+}
 
-		printStatement(be.Left, depth+1)
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
-		}
-		fmt.Printf(be.Operator.Lexeme + "\n\n")
-		printStatement(be.Right, depth+1)
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
-		}
-		fmt.Println("}")
-	case "models.LiteralExpr":
-		le := stmt.(models.LiteralExpr)
-		for i := 0; i < depth; i++ {
-			fmt.Printf("   ")
+func (p *printer) printStmt(stmt models.Stmt) {
+	stmt.Accept(p)
+}
+
+func (p *printer) printExpr(expr models.Expr) {
+	expr.Accept(p)
+}
+
+// line prints one line at the current depth.
+func (p *printer) line(format string, args ...interface{}) {
+	fmt.Printf("%s%s\n", strings.Repeat("   ", p.depth), fmt.Sprintf(format, args...))
+}
+
+// node prints label, then runs children one level deeper
+// between braces.
+func (p *printer) node(label string, children func()) {
+	p.line("%s {", label)
+	p.depth++
+	children()
+	p.depth--
+	p.line("}")
+}
+
+func (p *printer) block(statements []models.Stmt) {
+	for _, stmt := range statements {
+		p.printStmt(stmt)
+	}
+}
+
+func (p *printer) VisitExprStmt(stmt models.ExprStmt) (interface{}, error) {
+	p.node("Expression statement", func() { p.printExpr(stmt.Expression) })
+	return nil, nil
+}
+
+func (p *printer) VisitVarStmt(stmt models.VarStmt) (interface{}, error) {
+	p.node("Variable statement "+typeString(stmt.Type)+" "+stmt.Name.Lexeme, func() {
+		if stmt.Initializer != nil {
+			p.printExpr(stmt.Initializer)
 		}
-		fmt.Printf("Literal expression: %s\n\n", le.Literal.(string))
-	case "models.GroupingExpr":
-		ge := stmt.(models.GroupingExpr)
-		printStatement(ge.Expression, depth+1)
-	case "models.VarStmt":
-		vs := stmt.(models.VarStmt)
-		for i := 0; i < depth; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitBlockStmt(stmt models.BlockStmt) (interface{}, error) {
+	p.node("Block statement", func() { p.block(stmt.Statements) })
+	return nil, nil
+}
+
+func (p *printer) VisitIfStmt(stmt models.IfStmt) (interface{}, error) {
+	p.node("If statement", func() {
+		p.printExpr(stmt.Condition)
+		p.printStmt(stmt.ThenBranch)
+		if stmt.ElseBranch != nil {
+			p.node("Else", func() { p.printStmt(stmt.ElseBranch) })
 		}
-		fmt.Printf("Variable statement {\n")
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitWhileStmt(stmt models.WhileStmt) (interface{}, error) {
+	p.node("While statement"+labelSuffix(stmt.Label), func() {
+		p.printExpr(stmt.Condition)
+		p.printStmt(stmt.Body)
+		if stmt.Increment != nil {
+			p.printExpr(stmt.Increment)
 		}
-		fmt.Printf(vs.Name.Lexeme)
-		fmt.Printf(" {\n")
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitForEachStmt(stmt models.ForEachStmt) (interface{}, error) {
+	var vars []string
+	for _, v := range stmt.Vars {
+		vars = append(vars, typeString(v.Type)+" "+v.Name.Lexeme)
+	}
+
+	p.node("For-each statement "+strings.Join(vars, ", ")+labelSuffix(stmt.Label), func() {
+		p.printExpr(stmt.Iterable)
+		p.printStmt(stmt.Body)
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitFuncStmt(stmt models.FuncStmt) (interface{}, error) {
+	p.node("Function statement "+stmt.Name.Lexeme+signatureString(stmt.Params, stmt.ReturnType), func() { p.block(stmt.Body) })
+	return nil, nil
+}
+
+func (p *printer) VisitBreakStmt(stmt models.BreakStmt) (interface{}, error) {
+	p.line("Break statement" + labelSuffix(stmt.Label))
+	return nil, nil
+}
+
+func (p *printer) VisitContinueStmt(stmt models.ContinueStmt) (interface{}, error) {
+	p.line("Continue statement" + labelSuffix(stmt.Label))
+	return nil, nil
+}
+
+// labelSuffix describes a loop label, or
+// returns "" if there isn't one.
+func labelSuffix(label *models.Token) string {
+	if label == nil {
+		return ""
+	}
+	return " " + label.Lexeme
+}
+
+func (p *printer) VisitReturnStmt(stmt models.ReturnStmt) (interface{}, error) {
+	if stmt.Value == nil {
+		p.line("Return statement")
+		return nil, nil
+	}
+	p.node("Return statement", func() { p.printExpr(stmt.Value) })
+	return nil, nil
+}
+
+func (p *printer) VisitStructStmt(stmt models.StructStmt) (interface{}, error) {
+	p.node("Struct statement "+stmt.Name.Lexeme, func() {
+		for _, field := range stmt.Fields {
+			p.line("%s %s", typeString(field.Type), field.Name.Lexeme)
 		}
-		printStatement(vs.Initializer, depth+1)
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitErrorStmt(stmt models.ErrorStmt) (interface{}, error) {
+	p.line("Error statement")
+	return nil, nil
+}
+
+func (p *printer) VisitAssignExpr(expr models.AssignExpr) (interface{}, error) {
+	p.node("Assignment expression "+expr.Name.Lexeme+assignOperator(expr.Operator), func() { p.printExpr(expr.Value) })
+	return nil, nil
+}
+
+func (p *printer) VisitBinaryExpr(expr models.BinaryExpr) (interface{}, error) {
+	p.node("Binary expression "+expr.Operator.Lexeme, func() {
+		p.printExpr(expr.Left)
+		p.printExpr(expr.Right)
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitUnaryExpr(expr models.UnaryExpr) (interface{}, error) {
+	p.node("Unary expression "+expr.Operator.Lexeme, func() { p.printExpr(expr.Right) })
+	return nil, nil
+}
+
+func (p *printer) VisitLiteralExpr(expr models.LiteralExpr) (interface{}, error) {
+	p.line("Literal expression: %#v", expr.Literal)
+	return nil, nil
+}
+
+func (p *printer) VisitGroupingExpr(expr models.GroupingExpr) (interface{}, error) {
+	p.node("Grouping expression", func() { p.printExpr(expr.Expression) })
+	return nil, nil
+}
+
+func (p *printer) VisitVarExpr(expr models.VarExpr) (interface{}, error) {
+	p.line("Variable expression: %s", expr.Name.Lexeme)
+	return nil, nil
+}
+
+func (p *printer) VisitLogicExpr(expr models.LogicExpr) (interface{}, error) {
+	p.node("Logical expression "+expr.Operator.Lexeme, func() {
+		p.printExpr(expr.Left)
+		p.printExpr(expr.Right)
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitCallExpr(expr models.CallExpr) (interface{}, error) {
+	p.node("Call expression", func() {
+		p.printExpr(expr.Callee)
+		for _, argument := range expr.Arguments {
+			p.printExpr(argument)
 		}
-		fmt.Printf("}\n")
-		fmt.Println("}")
-	case "models.AssignExpr":
-		ae := stmt.(models.AssignExpr)
-		for i := 0; i < depth; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitListExpr(expr models.ListExpr) (interface{}, error) {
+	p.node("List expression", func() {
+		for _, element := range expr.Elements {
+			p.printExpr(element)
 		}
-		fmt.Printf("Assignment expression {\n")
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitMapExpr(expr models.MapExpr) (interface{}, error) {
+	p.node("Map expression", func() {
+		for _, entry := range expr.Entries {
+			p.node("Entry", func() {
+				p.printExpr(entry.Key)
+				p.printExpr(entry.Value)
+			})
 		}
-		fmt.Printf(ae.Name.Lexeme)
-		fmt.Printf(" { \n")
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitIndexExpr(expr models.IndexExpr) (interface{}, error) {
+	p.node("Index expression", func() {
+		p.printExpr(expr.Object)
+		p.printExpr(expr.Index)
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitIndexSetExpr(expr models.IndexSetExpr) (interface{}, error) {
+	p.node("Index assignment expression"+assignOperator(expr.Operator), func() {
+		p.printExpr(expr.Object)
+		p.printExpr(expr.Index)
+		p.printExpr(expr.Value)
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitGetExpr(expr models.GetExpr) (interface{}, error) {
+	p.node("Get expression ."+expr.Name.Lexeme, func() { p.printExpr(expr.Object) })
+	return nil, nil
+}
+
+func (p *printer) VisitSetExpr(expr models.SetExpr) (interface{}, error) {
+	p.node("Set expression ."+expr.Name.Lexeme+assignOperator(expr.Operator), func() {
+		p.printExpr(expr.Object)
+		p.printExpr(expr.Value)
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitStructExpr(expr models.StructExpr) (interface{}, error) {
+	p.node("Struct expression "+expr.Name.Lexeme, func() {
+		for _, field := range expr.Fields {
+			p.node(field.Name.Lexeme+":", func() { p.printExpr(field.Value) })
 		}
-		printStatement(ae.Value, depth+1)
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	})
+	return nil, nil
+}
+
+func (p *printer) VisitFuncExpr(expr models.FuncExpr) (interface{}, error) {
+	p.node("Function expression"+signatureString(expr.Params, expr.ReturnType), func() { p.block(expr.Body) })
+	return nil, nil
+}
+
+func (p *printer) VisitErrorExpr(expr models.ErrorExpr) (interface{}, error) {
+	p.line("Error expression")
+	return nil, nil
+}
+
+// typeString returns typeExpr as it would be written in source.
+func typeString(typeExpr models.TypeExpr) string {
+	switch typeExpr.Name.Type {
+	case models.LIST_VAR:
+		if len(typeExpr.Args) == 1 {
+			return "list<" + typeString(typeExpr.Args[0]) + ">"
 		}
-		fmt.Printf("}\n")
-		fmt.Println("}")
-	case "models.ExprStmt":
-		es := stmt.(models.ExprStmt)
-		for i := 0; i < depth; i++ {
-			fmt.Printf("   ")
+	case models.MAP_VAR:
+		if len(typeExpr.Args) == 2 {
+			return "map<" + typeString(typeExpr.Args[0]) + ", " + typeString(typeExpr.Args[1]) + ">"
 		}
-		fmt.Printf("Expression statement {\n")
-		for i := 0; i < depth+1; i++ {
-			fmt.Printf("   ")
+	case models.FUNC:
+		var params []string
+		for _, param := range typeExpr.Args {
+			params = append(params, typeString(param))
 		}
-		printStatement(es.Expression, depth+1)
-		for i := 0; i < depth; i++ {
-			fmt.Printf("   ")
+		result := "func(" + strings.Join(params, ", ") + ")"
+		if typeExpr.Return != nil {
+			result += " " + typeString(*typeExpr.Return)
 		}
-		fmt.Printf("}\n")
+		return result
+	}
+
+	return typeExpr.Name.Lexeme
+}
+
+// signatureString returns the parameter list and return
+// type of a function as they would be written in source.
+func signatureString(params []models.FuncParam, returnType *models.TypeExpr) string {
+	var parts []string
+	for _, param := range params {
+		parts = append(parts, typeString(param.Type)+" "+param.Name.Lexeme)
+	}
+
+	result := "(" + strings.Join(parts, ", ") + ")"
+	if returnType != nil {
+		result += " " + typeString(*returnType)
+	}
+	return result
+}
+
+// assignOperator describes the operator of a compound
+// assignment, or returns "" for a plain one.
+func assignOperator(operator *models.Token) string {
+	if operator == nil {
+		return ""
 	}
+	return " " + operator.Lexeme
 }
@@ -0,0 +1,83 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+)
+
+func (r *Resolver) reportError(token models.Token, message string, help ...string) {
+	r.resolveErrors = append(r.resolveErrors, &ResolveError{
+		Line:    token.Line,
+		Column:  token.Column,
+		Length:  token.Span().Width(),
+		Message: message,
+		Help:    help,
+	})
+}
+
+// reportDeclared reports a problem with the declaration of
+// token that involves the earlier declaration declared.
+func (r *Resolver) reportDeclared(token models.Token, declared models.Token, message string, warning bool, help string) {
+	r.resolveErrors = append(r.resolveErrors, &ResolveError{
+		Line:     token.Line,
+		Column:   token.Column,
+		Length:   token.Span().Width(),
+		Message:  message,
+		Declared: &declared,
+		Warning:  warning,
+		Help:     []string{help},
+	})
+}
+
+type ResolveError struct {
+	Line    int
+	Column  int
+	Length  int
+	Message string
+	// Declared is the earlier declaration the
+	// error is about, if there is one.
+	Declared *models.Token
+	// Warning is whether the error is only a warning,
+	// which doesn't stop the program from running.
+	Warning bool
+	// Help holds suggestions for fixing the error.
+	Help []string
+}
+
+func (e *ResolveError) Error() string {
+	severity := "Error"
+	if e.Warning {
+		severity = "Warning"
+	}
+
+	message := fmt.Sprintf("[Line %d:%d] %s: %s", e.Line, e.Column, severity, e.Message)
+	if e.Declared != nil {
+		message += fmt.Sprintf("\n    [Line %d:%d] Note: '%s' is declared here.", e.Declared.Line, e.Declared.Column, e.Declared.Lexeme)
+	}
+	return message
+}
+
+func (e *ResolveError) Diagnostic() *diagnostics.Diagnostic {
+	diagnostic := &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Line,
+		Column:   e.Column,
+		Length:   e.Length,
+		Message:  e.Message,
+		Help:     e.Help,
+	}
+	if e.Warning {
+		diagnostic.Severity = diagnostics.Warning
+	}
+	if e.Declared != nil {
+		diagnostic.Notes = append(diagnostic.Notes, diagnostics.Note{
+			Line:    e.Declared.Line,
+			Column:  e.Declared.Column,
+			Length:  e.Declared.Span().Width(),
+			Message: fmt.Sprintf("'%s' is declared here.", e.Declared.Lexeme),
+		})
+	}
+	return diagnostic
+}
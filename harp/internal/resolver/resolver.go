@@ -0,0 +1,615 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/astraikis/harp/internal/models"
+)
+
+// Resolver binds every name that refers to a local
+// variable to the scope and slot it lives in, and finds
+// mistakes in how local variables are used. Globals are
+// left to be looked up by name at runtime.
+type Resolver struct {
+	// scopes holds the local scopes around the code being
+	// resolved, innermost last. The global scope isn't in it.
+	scopes []*scope
+	// functions counts the function bodies around
+	// the code being resolved.
+	functions int
+	// declaring holds the local functions whose
+	// bodies are being resolved, innermost last.
+	declaring []*variable
+	// globals holds the globals declared in the
+	// stmts being resolved, by name, and pendingGlobals
+	// counts the declarations of each global still to come.
+	globals        map[string]*global
+	pendingGlobals map[string]*pendingDecl
+	resolveErrors  []error
+}
+
+// scope is a block or function body. Each scope
+// becomes one Environment at runtime.
+type scope struct {
+	variables map[string]*variable
+	// pending holds the declarations of each name still
+	// to come in the scope, which can't be used yet.
+	pending map[string]*pendingDecl
+	// usedEarly holds the names used before their
+	// declarations, which have been reported already.
+	usedEarly map[string]bool
+	slots     int
+	// function is the number of function bodies
+	// the scope is nested in.
+	function int
+}
+
+// pendingDecl is the declarations of a name still to
+// come in a scope: how many there are and what the
+// first of them declares.
+type pendingDecl struct {
+	count int
+	kind  declKind
+}
+
+// notePending adds stmt to pending if it declares a name.
+func notePending(pending map[string]*pendingDecl, stmt models.Stmt) {
+	var key string
+	var kind declKind
+	switch stmt := stmt.(type) {
+	case models.VarStmt:
+		key, kind = stmt.Name.Lexeme, variableDecl
+	case models.FuncStmt:
+		key, kind = stmt.Binding.RuntimeName(stmt.Name).Lexeme, functionDecl
+	case models.StructStmt:
+		key, kind = stmt.Name.Lexeme, structDecl
+	default:
+		return
+	}
+
+	if p, ok := pending[key]; ok {
+		p.count++
+		return
+	}
+	pending[key] = &pendingDecl{count: 1, kind: kind}
+}
+
+// global is a declaration at the top level.
+type global struct {
+	name models.Token
+	kind declKind
+	// ready is false while the global's
+	// initializer is being resolved.
+	ready bool
+}
+
+type variable struct {
+	name models.Token
+	kind declKind
+	slot int
+	// ready is false while the variable's
+	// initializer is being resolved.
+	ready bool
+	used  bool
+	// checkUnused is whether to report the
+	// variable if it's never read.
+	checkUnused bool
+	isParam     bool
+	// hoisted is whether the variable is a local function,
+	// declared when its scope begins so that the functions
+	// in the scope can call each other.
+	hoisted bool
+	// function is the function depth of the
+	// variable's scope, like scope.function.
+	function int
+	// needs holds the local functions a hoisted function
+	// uses from inside its body, which must all have been
+	// declared by the time it's used.
+	needs []*variable
+}
+
+// NewResolver returns a Resolver.
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// Resolve binds the names in statements and
+// returns every error found in them.
+func Resolve(statements []models.Stmt) []error {
+	return NewResolver().Resolve(statements)
+}
+
+// Resolve binds the names in statements and returns
+// every error and warning found in them. Use IsWarning
+// to tell them apart. Each call starts a fresh global
+// scope, so globals can be declared again in later ones.
+func (r *Resolver) Resolve(statements []models.Stmt) []error {
+	r.resolveErrors = nil
+	r.globals = map[string]*global{}
+	r.pendingGlobals = map[string]*pendingDecl{}
+	for _, stmt := range statements {
+		notePending(r.pendingGlobals, stmt)
+	}
+
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+
+	return r.resolveErrors
+}
+
+func (r *Resolver) resolveStmt(stmt models.Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *Resolver) VisitExprStmt(stmt models.ExprStmt) (interface{}, error) {
+	r.resolveExpr(stmt.Expression)
+	return nil, nil
+}
+
+func (r *Resolver) VisitBlockStmt(stmt models.BlockStmt) (interface{}, error) {
+	r.beginScope(stmt.Statements)
+	r.resolveBlock(stmt.Statements)
+	r.endScope()
+	return nil, nil
+}
+
+func (r *Resolver) VisitIfStmt(stmt models.IfStmt) (interface{}, error) {
+	r.resolveExpr(stmt.Condition)
+	r.resolveStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		r.resolveStmt(stmt.ElseBranch)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitWhileStmt(stmt models.WhileStmt) (interface{}, error) {
+	r.resolveExpr(stmt.Condition)
+	r.resolveStmt(stmt.Body)
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitForEachStmt(stmt models.ForEachStmt) (interface{}, error) {
+	// The loop variables aren't in scope in the iterable.
+	r.resolveExpr(stmt.Iterable)
+
+	r.beginScope(nil)
+	for _, v := range stmt.Vars {
+		r.declare(v.Name, &models.Binding{}, variableDecl)
+		r.define(v.Name)
+	}
+	r.resolveStmt(stmt.Body)
+	r.endScope()
+	return nil, nil
+}
+
+func (r *Resolver) VisitBreakStmt(stmt models.BreakStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (r *Resolver) VisitContinueStmt(stmt models.ContinueStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (r *Resolver) VisitFuncStmt(stmt models.FuncStmt) (interface{}, error) {
+	// Define the function before resolving its body so it
+	// can call itself. Each overload has its own variable.
+	// Local functions were declared by resolveBlock.
+	name := stmt.Binding.RuntimeName(stmt.Name)
+	if len(r.scopes) == 0 {
+		r.declare(stmt.Name, stmt.Binding, functionDecl)
+		r.define(name)
+		r.resolveFunction(stmt.Params, stmt.Body)
+		return nil, nil
+	}
+
+	r.define(name)
+	r.declaring = append(r.declaring, r.scopes[len(r.scopes)-1].variables[name.Lexeme])
+	r.resolveFunction(stmt.Params, stmt.Body)
+	r.declaring = r.declaring[:len(r.declaring)-1]
+	return nil, nil
+}
+
+func (r *Resolver) VisitStructStmt(stmt models.StructStmt) (interface{}, error) {
+	r.declare(stmt.Name, stmt.Binding, structDecl)
+	r.define(stmt.Name)
+	return nil, nil
+}
+
+func (r *Resolver) VisitReturnStmt(stmt models.ReturnStmt) (interface{}, error) {
+	if stmt.Value != nil {
+		r.resolveExpr(stmt.Value)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitErrorStmt(stmt models.ErrorStmt) (interface{}, error) {
+	return nil, nil
+}
+
+// resolveBlock resolves the statements of the innermost
+// scope. The local functions among them are declared
+// first, so that their bodies can call each other.
+func (r *Resolver) resolveBlock(statements []models.Stmt) {
+	for _, stmt := range statements {
+		if stmt, ok := stmt.(models.FuncStmt); ok && len(r.scopes) > 0 {
+			if v := r.declare(stmt.Name, stmt.Binding, functionDecl); v != nil {
+				v.hoisted = true
+			}
+		}
+	}
+
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *Resolver) VisitVarStmt(stmt models.VarStmt) (interface{}, error) {
+	if v := r.declare(stmt.Name, stmt.Binding, variableDecl); v != nil {
+		v.checkUnused = true
+	}
+	if stmt.Initializer != nil {
+		r.resolveExpr(stmt.Initializer)
+	}
+	r.define(stmt.Name)
+	return nil, nil
+}
+
+// resolveFunction resolves a function body in a new scope
+// holding its parameters, in slots matching their order.
+func (r *Resolver) resolveFunction(params []models.FuncParam, body []models.Stmt) {
+	r.functions++
+	defer func() { r.functions-- }()
+
+	r.beginScope(body)
+	for _, param := range params {
+		if v := r.declare(param.Name, &models.Binding{}, variableDecl); v != nil {
+			v.isParam = true
+		}
+		r.define(param.Name)
+	}
+	r.resolveBlock(body)
+	r.endScope()
+}
+
+func (r *Resolver) resolveExpr(expr models.Expr) {
+	expr.Accept(r)
+}
+
+func (r *Resolver) VisitBinaryExpr(expr models.BinaryExpr) (interface{}, error) {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
+	return nil, nil
+}
+
+func (r *Resolver) VisitUnaryExpr(expr models.UnaryExpr) (interface{}, error) {
+	r.resolveExpr(expr.Right)
+	return nil, nil
+}
+
+func (r *Resolver) VisitLiteralExpr(expr models.LiteralExpr) (interface{}, error) {
+	return nil, nil
+}
+
+func (r *Resolver) VisitGroupingExpr(expr models.GroupingExpr) (interface{}, error) {
+	r.resolveExpr(expr.Expression)
+	return nil, nil
+}
+
+func (r *Resolver) VisitVarExpr(expr models.VarExpr) (interface{}, error) {
+	r.resolveName(expr.Name, expr.Binding, true)
+	return nil, nil
+}
+
+func (r *Resolver) VisitAssignExpr(expr models.AssignExpr) (interface{}, error) {
+	r.resolveExpr(expr.Value)
+	r.resolveName(expr.Name, expr.Binding, false)
+	return nil, nil
+}
+
+func (r *Resolver) VisitLogicExpr(expr models.LogicExpr) (interface{}, error) {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
+	return nil, nil
+}
+
+func (r *Resolver) VisitCallExpr(expr models.CallExpr) (interface{}, error) {
+	r.resolveExpr(expr.Callee)
+	for _, argument := range expr.Arguments {
+		r.resolveExpr(argument)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitListExpr(expr models.ListExpr) (interface{}, error) {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitMapExpr(expr models.MapExpr) (interface{}, error) {
+	for _, entry := range expr.Entries {
+		r.resolveExpr(entry.Key)
+		r.resolveExpr(entry.Value)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr models.IndexExpr) (interface{}, error) {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil, nil
+}
+
+func (r *Resolver) VisitIndexSetExpr(expr models.IndexSetExpr) (interface{}, error) {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	r.resolveExpr(expr.Value)
+	return nil, nil
+}
+
+func (r *Resolver) VisitStructExpr(expr models.StructExpr) (interface{}, error) {
+	r.resolveName(expr.Name, expr.Binding, true)
+	for _, field := range expr.Fields {
+		r.resolveExpr(field.Value)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitGetExpr(expr models.GetExpr) (interface{}, error) {
+	r.resolveExpr(expr.Object)
+	return nil, nil
+}
+
+func (r *Resolver) VisitSetExpr(expr models.SetExpr) (interface{}, error) {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Value)
+	return nil, nil
+}
+
+func (r *Resolver) VisitFuncExpr(expr models.FuncExpr) (interface{}, error) {
+	r.resolveFunction(expr.Params, expr.Body)
+	return nil, nil
+}
+
+func (r *Resolver) VisitErrorExpr(expr models.ErrorExpr) (interface{}, error) {
+	return nil, nil
+}
+
+// resolveName binds name to the innermost local variable
+// declared with it. If there isn't one, binding is left
+// as a global. Read is whether the name is being read
+// rather than assigned to.
+//
+// Globals are looked up by name when they're used, so
+// only the uses outside function bodies have to come
+// after the global is declared.
+func (r *Resolver) resolveName(name models.Token, binding *models.Binding, read bool) {
+	key := binding.RuntimeName(name).Lexeme
+	for depth := 0; depth < len(r.scopes); depth++ {
+		s := r.scopes[len(r.scopes)-1-depth]
+
+		if v, ok := s.variables[key]; ok {
+			if v.hoisted && s.function == r.functions {
+				if !v.ready {
+					r.reportError(name, fmt.Sprintf("Cannot use local %s '%s' before its declaration.", v.kind, name.Lexeme))
+					v.used = true
+					r.markHiddenUsed(name, depth)
+					return
+				}
+				if missing := undeclaredNeed(v, map[*variable]bool{}); missing != nil {
+					r.reportError(name, fmt.Sprintf("Cannot use local function '%s' here, because it uses '%s', which isn't declared yet.", name.Lexeme, missing.name.Lexeme))
+				}
+			} else if v.hoisted {
+				// Used from inside a function body, which may only
+				// run once v is declared. The functions declaring
+				// that body depend on v instead.
+				for _, d := range r.declaring {
+					if d.function >= v.function {
+						d.needs = append(d.needs, v)
+					}
+				}
+			}
+			if !v.ready && !v.hoisted {
+				r.reportError(name, fmt.Sprintf("Cannot read local variable '%s' in its own initializer.", name.Lexeme))
+			}
+			if read {
+				v.used = true
+			}
+			binding.Local, binding.Depth, binding.Slot = true, depth, v.slot
+			return
+		}
+
+		if p, ok := s.pending[key]; ok && p.count > 0 {
+			r.reportError(name, fmt.Sprintf("Cannot use local %s '%s' before its declaration.", p.kind, name.Lexeme))
+			s.usedEarly[key] = true
+			r.markHiddenUsed(name, depth)
+			return
+		}
+	}
+
+	if r.functions > 0 {
+		return
+	}
+	if g, ok := r.globals[key]; ok {
+		if !g.ready {
+			r.reportError(name, fmt.Sprintf("Cannot read variable '%s' in its own initializer.", name.Lexeme))
+		}
+		return
+	}
+	if p, ok := r.pendingGlobals[key]; ok && p.count > 0 {
+		r.reportError(name, fmt.Sprintf("Cannot use %s '%s' before its declaration.", p.kind, name.Lexeme))
+	}
+}
+
+// markHiddenUsed marks the variable called name in the
+// scopes around the one depth scopes out as used. An early
+// use of a name that hides it has been reported already,
+// so it isn't also reported as never used.
+func (r *Resolver) markHiddenUsed(name models.Token, depth int) {
+	for depth++; depth < len(r.scopes); depth++ {
+		if v, ok := r.scopes[len(r.scopes)-1-depth].variables[name.Lexeme]; ok {
+			v.used = true
+			return
+		}
+	}
+}
+
+// undeclaredNeed returns a local function that v uses, directly
+// or through the functions it uses, that's declared in v's
+// scope but hasn't been declared yet, or nil if there isn't one.
+func undeclaredNeed(v *variable, seen map[*variable]bool) *variable {
+	seen[v] = true
+	for _, need := range v.needs {
+		if need.function == v.function && !need.ready {
+			return need
+		}
+		if !seen[need] {
+			if missing := undeclaredNeed(need, seen); missing != nil {
+				return missing
+			}
+		}
+	}
+	return nil
+}
+
+// declare adds name to the innermost scope, binds the
+// declaration to its slot and returns the new variable.
+// At the top level it only records the global and
+// returns nil. Declaring a name twice in one scope is
+// an error, which binds to the earlier variable and
+// returns nil, and hiding a local of an outer scope
+// gets a warning.
+func (r *Resolver) declare(name models.Token, binding *models.Binding, kind declKind) *variable {
+	// Overloads of a function are kept apart by
+	// the names they're stored under at runtime.
+	key := binding.RuntimeName(name).Lexeme
+	if len(r.scopes) == 0 {
+		if declared, ok := r.globals[key]; ok {
+			r.reportDeclared(name, declared.name, fmt.Sprintf("'%s' is already declared in this scope.", name.Lexeme), false, redeclaredHelp(name, kind, declared.kind))
+		}
+		if p, ok := r.pendingGlobals[key]; ok && p.count > 0 {
+			p.count--
+		}
+		r.globals[key] = &global{name: name, kind: kind}
+		return nil
+	}
+
+	s := r.scopes[len(r.scopes)-1]
+	if p, ok := s.pending[key]; ok && p.count > 0 {
+		p.count--
+	}
+
+	// The earlier variable takes the place of the new one,
+	// and isn't reported as unused on top of this error.
+	if v, ok := s.variables[key]; ok {
+		r.reportDeclared(name, v.name, fmt.Sprintf("'%s' is already declared in this scope.", name.Lexeme), false, redeclaredHelp(name, kind, v.kind))
+		binding.Local, binding.Depth, binding.Slot = true, 0, v.slot
+		v.checkUnused = false
+		return nil
+	}
+
+	for depth := len(r.scopes) - 2; depth >= 0; depth-- {
+		if outer, ok := r.scopes[depth].variables[name.Lexeme]; ok {
+			hidden := "local variable"
+			if outer.isParam {
+				hidden = "parameter"
+			}
+			r.reportDeclared(name, outer.name, fmt.Sprintf("'%s' shadows a %s.", name.Lexeme, hidden), true, fmt.Sprintf("Rename it if it isn't meant to hide the outer '%s'.", name.Lexeme))
+			break
+		}
+	}
+
+	v := &variable{name: name, kind: kind, slot: s.slots, function: s.function, used: s.usedEarly[key]}
+	s.variables[key] = v
+	s.slots++
+
+	binding.Local, binding.Depth, binding.Slot = true, 0, v.slot
+	return v
+}
+
+// declKind is what a declaration declares.
+type declKind int
+
+const (
+	variableDecl declKind = iota
+	functionDecl
+	structDecl
+)
+
+func (k declKind) String() string {
+	switch k {
+	case functionDecl:
+		return "function"
+	case structDecl:
+		return "struct"
+	}
+	return "variable"
+}
+
+// redeclaredHelp suggests how to fix declaring name as a
+// kind of declaration when it's already declared as earlier.
+// Only a function can be overloaded, and only by another.
+func redeclaredHelp(name models.Token, kind declKind, earlier declKind) string {
+	switch {
+	case kind == functionDecl && earlier == functionDecl:
+		return fmt.Sprintf("Give it another name, or give it different parameters to overload '%s'.", name.Lexeme)
+	case kind == structDecl:
+		return "Give the struct another name."
+	case kind == variableDecl && earlier == variableDecl:
+		return fmt.Sprintf("Give it another name, or assign to '%s' without declaring it again.", name.Lexeme)
+	}
+	return fmt.Sprintf("Give it another name, because '%s' is already declared as a %s.", name.Lexeme, earlier)
+}
+
+// define marks the variable name in the innermost
+// scope, or the global name, as ready to be read.
+func (r *Resolver) define(name models.Token) {
+	if len(r.scopes) == 0 {
+		r.globals[name.Lexeme].ready = true
+		return
+	}
+
+	r.scopes[len(r.scopes)-1].variables[name.Lexeme].ready = true
+}
+
+// beginScope starts a scope for statements, noting
+// the names they declare so uses before the
+// declarations can be reported.
+func (r *Resolver) beginScope(statements []models.Stmt) {
+	s := &scope{variables: map[string]*variable{}, pending: map[string]*pendingDecl{}, usedEarly: map[string]bool{}, function: r.functions}
+	for _, stmt := range statements {
+		// Local functions are declared as the scope
+		// begins, so they're never pending.
+		if _, ok := stmt.(models.FuncStmt); !ok {
+			notePending(s.pending, stmt)
+		}
+	}
+
+	r.scopes = append(r.scopes, s)
+}
+
+// endScope ends the innermost scope, reporting
+// the variables in it that were never read.
+func (r *Resolver) endScope() {
+	s := r.scopes[len(r.scopes)-1]
+	r.scopes = r.scopes[:len(r.scopes)-1]
+
+	var unused []*variable
+	for _, v := range s.variables {
+		if v.checkUnused && !v.used {
+			unused = append(unused, v)
+		}
+	}
+	// Report in the order the variables were declared.
+	sort.Slice(unused, func(a, b int) bool { return unused[a].slot < unused[b].slot })
+
+	for _, v := range unused {
+		r.reportError(v.name, fmt.Sprintf("Local variable '%s' is declared but never used.", v.name.Lexeme), fmt.Sprintf("Remove '%s' if it isn't needed.", v.name.Lexeme))
+	}
+}
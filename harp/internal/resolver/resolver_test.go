@@ -0,0 +1,343 @@
+package resolver_test
+
+import (
+	"testing"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/resolver"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+// resolveTests pairs sources with the exact errors and
+// warnings the resolver reports for them, in order.
+// Sources with nothing to report have a nil want.
+var resolveTests = []struct {
+	name   string
+	source string
+	want   []string
+}{
+	{
+		name: "local functions calling each other",
+		source: `func g() {
+	func isEven(int n) bool { return n == 0 or isOdd(n - 1); }
+	func isOdd(int n) bool { return n != 0 and isEven(n - 1); }
+	print(isEven(4));
+}`,
+	},
+	{
+		name: "local function used before one it calls is declared",
+		source: `func g() {
+	func f() int { return h(); }
+	print(f());
+	func h() int { return 3; }
+}`,
+		want: []string{"[Line 3:8] Error: Cannot use local function 'f' here, because it uses 'h', which isn't declared yet."},
+	},
+	{
+		name: "local function calling one declared later through another",
+		source: `func g() {
+	func f() int { return k(); }
+	func k() int { return h(); }
+	func run() { print(f()); }
+	run();
+	func h() int { return 3; }
+}`,
+		want: []string{"[Line 5:2] Error: Cannot use local function 'run' here, because it uses 'h', which isn't declared yet."},
+	},
+	{
+		name: "local function used once everything it calls is declared",
+		source: `func g() {
+	func f() int { return h(); }
+	func h() int { return 3; }
+	print(f());
+}`,
+	},
+	{
+		name: "nested local function calling an outer one declared later",
+		source: `func g() {
+	func outer() int {
+		func inner() int { return h(); }
+		return inner();
+	}
+	func h() int { return 3; }
+	print(outer());
+}`,
+	},
+	{
+		name: "variable used before its declaration",
+		source: `func g() {
+	print(x);
+	int x = 1;
+}`,
+		want: []string{"[Line 2:8] Error: Cannot use local variable 'x' before its declaration."},
+	},
+	{
+		name: "local function used before its declaration",
+		source: `func g() {
+	f();
+	func f() {}
+}`,
+		want: []string{"[Line 2:2] Error: Cannot use local function 'f' before its declaration."},
+	},
+	{
+		name: "local struct used before its declaration",
+		source: `func g() {
+	P p = P{};
+	struct P {}
+	print(p);
+}`,
+		want: []string{"[Line 2:8] Error: Cannot use local struct 'P' before its declaration."},
+	},
+	{
+		name: "local used before a later function hides it",
+		source: `func g() {
+	int h = 1;
+	{
+		print(h);
+		func h() {}
+	}
+}`,
+		want: []string{
+			"[Line 5:8] Warning: 'h' shadows a local variable.\n    [Line 2:6] Note: 'h' is declared here.",
+			"[Line 4:9] Error: Cannot use local function 'h' before its declaration.",
+		},
+	},
+	{
+		name: "local used before a later variable hides it",
+		source: `func g() {
+	int h = 1;
+	{
+		print(h);
+		int h = 2;
+		print(h);
+	}
+}`,
+		want: []string{
+			"[Line 4:9] Error: Cannot use local variable 'h' before its declaration.",
+			"[Line 5:7] Warning: 'h' shadows a local variable.\n    [Line 2:6] Note: 'h' is declared here.",
+		},
+	},
+	{
+		name:   "parameter declared again",
+		source: `func f(int b) { int b = 1; }`,
+		want:   []string{"[Line 1:21] Error: 'b' is already declared in this scope.\n    [Line 1:12] Note: 'b' is declared here."},
+	},
+	{
+		name: "variable declared again",
+		source: `func g() {
+	int a = 1;
+	int a = 2;
+}`,
+		want: []string{"[Line 3:6] Error: 'a' is already declared in this scope.\n    [Line 2:6] Note: 'a' is declared here."},
+	},
+	{
+		name: "local shadowing a local",
+		source: `func g() {
+	int a = 1;
+	{
+		int a = 2;
+		print(a);
+	}
+	print(a);
+}`,
+		want: []string{"[Line 4:7] Warning: 'a' shadows a local variable.\n    [Line 2:6] Note: 'a' is declared here."},
+	},
+	{
+		name:   "local shadowing a parameter",
+		source: `func g(int a) { { int a = 2; print(a); } }`,
+		want:   []string{"[Line 1:23] Warning: 'a' shadows a parameter.\n    [Line 1:12] Note: 'a' is declared here."},
+	},
+	{
+		name: "unused variable",
+		source: `func g() {
+	int a = 1;
+}`,
+		want: []string{"[Line 2:6] Error: Local variable 'a' is declared but never used."},
+	},
+	{
+		name:   "unused local function",
+		source: `func g() { func f() {} }`,
+	},
+	{
+		name:   "local read in its own initializer",
+		source: `func g() { int a = a; }`,
+		want:   []string{"[Line 1:20] Error: Cannot read local variable 'a' in its own initializer."},
+	},
+	{
+		name: "local captured before its declaration",
+		source: `{
+	func f() { print(y); }
+	int y = 1;
+	f();
+}`,
+		want: []string{"[Line 2:19] Error: Cannot use local variable 'y' before its declaration."},
+	},
+	{
+		name:   "global read in its own initializer",
+		source: `int x = x + 1;`,
+		want:   []string{"[Line 1:9] Error: Cannot read variable 'x' in its own initializer."},
+	},
+	{
+		name: "global used before its declaration",
+		source: `print(z);
+int z = 1;`,
+		want: []string{"[Line 1:7] Error: Cannot use variable 'z' before its declaration."},
+	},
+	{
+		name: "global function called before its declaration",
+		source: `f();
+func f() {}`,
+		want: []string{"[Line 1:1] Error: Cannot use function 'f' before its declaration."},
+	},
+	{
+		name: "global used in a function declared before it",
+		source: `func f() { print(y); }
+int y = 2;
+f();`,
+	},
+	{
+		name:   "unused loop variable",
+		source: `func g() { for (int i in range(0, 3)) {} }`,
+	},
+}
+
+func TestResolve(t *testing.T) {
+	for _, test := range resolveTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := resolve(t, test.source)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d errors, want %d\ngot:  %q\nwant: %q", len(got), len(test.want), got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("error %d = %q, want %q", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+// resolve parses and checks source, which must parse
+// cleanly, and returns the resolver's errors and warnings
+// as strings. The checker's errors are left out.
+func resolve(t *testing.T, source string) []string {
+	t.Helper()
+
+	stmts := parse(t, source)
+	checker.Check(stmts)
+
+	var messages []string
+	for _, err := range resolver.Resolve(stmts) {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// parse scans and parses source, which must parse cleanly.
+func parse(t *testing.T, source string) []models.Stmt {
+	t.Helper()
+
+	tokens, errs := scanner.Scan(source)
+	if len(errs) > 0 {
+		t.Fatalf("scan: %v", errs)
+	}
+	stmts, errs := parser.Parse(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse: %v", errs)
+	}
+	return stmts
+}
+
+func TestRedeclarationDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		length int
+		help   string
+	}{
+		{
+			name: "variable",
+			source: `func g() {
+	int ñé = 1;
+	int ñé = 2;
+	print(ñé);
+}`,
+			length: 2,
+			help:   "Give it another name, or assign to 'ñé' without declaring it again.",
+		},
+		{
+			name: "function",
+			source: `func add(int a) int { return a; }
+func add(int b) int { return b; }`,
+			length: 3,
+			help:   "Give it another name, or give it different parameters to overload 'add'.",
+		},
+		{
+			name: "struct",
+			source: `struct P { int x; }
+struct P { int y; }`,
+			length: 1,
+			help:   "Give the struct another name.",
+		},
+		{
+			name: "function after a variable",
+			source: `int f = 1;
+func f(int a) {}`,
+			length: 1,
+			help:   "Give it another name, because 'f' is already declared as a variable.",
+		},
+		{
+			name: "function after a struct",
+			source: `struct S {}
+func S() {}`,
+			length: 1,
+			help:   "Give it another name, because 'S' is already declared as a struct.",
+		},
+		{
+			name: "variable after a function",
+			source: `func q() {}
+int q = 2;`,
+			length: 1,
+			help:   "Give it another name, because 'q' is already declared as a function.",
+		},
+		{
+			name: "struct after a function",
+			source: `func P() {}
+struct P { int x; }`,
+			length: 1,
+			help:   "Give the struct another name.",
+		},
+		{
+			name: "local variable after a local function",
+			source: `func g() {
+	func a() {}
+	int a = 1;
+	print(a);
+}`,
+			length: 1,
+			help:   "Give it another name, because 'a' is already declared as a function.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stmts := parse(t, test.source)
+			checker.Check(stmts)
+			errs := resolver.Resolve(stmts)
+			if len(errs) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+			}
+
+			diagnostic := diagnostics.From(errs[0])
+			if diagnostic.Length != test.length {
+				t.Errorf("length = %d, want %d", diagnostic.Length, test.length)
+			}
+			if len(diagnostic.Help) != 1 || diagnostic.Help[0] != test.help {
+				t.Errorf("help = %q, want %q", diagnostic.Help, test.help)
+			}
+		})
+	}
+}
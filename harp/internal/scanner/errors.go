@@ -0,0 +1,37 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+)
+
+func (s *Scanner) reportError(line int, column int, length int, message string) {
+	s.scanErrors = append(s.scanErrors, &ScanError{
+		Line:    line,
+		Column:  column,
+		Length:  length,
+		Message: message,
+	})
+}
+
+type ScanError struct {
+	Line    int
+	Column  int
+	Length  int
+	Message string
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("[Line %d:%d] Error: %s", e.Line, e.Column, e.Message)
+}
+
+func (e *ScanError) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Line,
+		Column:   e.Column,
+		Length:   e.Length,
+		Message:  e.Message,
+	}
+}
@@ -3,265 +3,520 @@ package scanner
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/astraikis/harp/internal/models"
 )
 
-var source string
-var tokens []models.Token
-var start int = 0
-var current int = 0
-var line int = 1
-var column int = 1
+// Scanner turns source text into tokens. Each
+// Scanner holds its own state, so separate
+// Scanners can run at the same time.
+type Scanner struct {
+	source  string
+	tokens  []models.Token
+	start   int
+	current int
+	line    int
+	// lineStart is the offset of the first
+	// character of the current line.
+	lineStart int
+	// startLine and startColumn are where
+	// the token being scanned begins.
+	startLine   int
+	startColumn int
+	scanErrors  []error
+}
 
 var keywords = map[string]models.TokenType{
-	"and":    models.AND,
-	"else":   models.ELSE,
-	"false":  models.FALSE,
-	"func":   models.FUNC,
-	"for":    models.FOR,
-	"if":     models.IF,
-	"null":   models.NULL,
-	"or":     models.OR,
-	"return": models.RETURN,
-	"true":   models.TRUE,
-	"while":  models.WHILE,
-	"struct": models.STRUCT,
-	"string": models.STRING_VAR,
-	"int":    models.INT_VAR,
-	"double": models.DOUBLE_VAR,
-	"bool":   models.BOOL_VAR,
+	"and":      models.AND,
+	"else":     models.ELSE,
+	"false":    models.FALSE,
+	"func":     models.FUNC,
+	"for":      models.FOR,
+	"if":       models.IF,
+	"null":     models.NULL,
+	"or":       models.OR,
+	"return":   models.RETURN,
+	"true":     models.TRUE,
+	"while":    models.WHILE,
+	"struct":   models.STRUCT,
+	"break":    models.BREAK,
+	"continue": models.CONTINUE,
+	"in":       models.IN,
+	"string":   models.STRING_VAR,
+	"int":      models.INT_VAR,
+	"double":   models.DOUBLE_VAR,
+	"bool":     models.BOOL_VAR,
+	"list":     models.LIST_VAR,
+}
+
+// NewScanner returns a Scanner for source.
+func NewScanner(source string) *Scanner {
+	return &Scanner{source: source, line: 1}
 }
 
-// scan walks over source and returns
-// a corresponding list of tokens.
-func Scan(sourceText string) []models.Token {
-	source = sourceText
+// Scan walks over source and returns a corresponding
+// list of tokens and every error found in it.
+func Scan(source string) ([]models.Token, []error) {
+	return NewScanner(source).Scan()
+}
+
+// Scan walks over the Scanner's source and returns a
+// corresponding list of tokens and every error found
+// in it. Scanning carries on past errors, skipping the
+// characters that caused them.
+func (s *Scanner) Scan() ([]models.Token, []error) {
 	for {
-		if isAtEnd() {
+		if s.isAtEnd() {
 			break
 		}
-		start = current
-		scanToken()
+		s.start = s.current
+		s.startLine = s.line
+		s.startColumn = s.columnAt(s.start)
+		s.scanToken()
 	}
 
-	tokens = append(tokens, models.Token{Type: models.EOF, Lexeme: "", Literal: nil, Column: 1, Line: line})
-	return tokens
+	end := s.position()
+	s.tokens = append(s.tokens, models.Token{Type: models.EOF, Lexeme: "", Literal: nil, Offset: end.Offset, Column: end.Column, Line: end.Line, End: end})
+	return s.tokens, s.scanErrors
 }
 
 // scanToken adds the next token to tokens.
-func scanToken() {
-	var c rune = advance()
+func (s *Scanner) scanToken() {
+	var c rune = s.advance()
 	switch c {
 	case '(':
-		addToken(models.LeftParen, "")
-		column += 1
+		s.addToken(models.LeftParen, "")
 	case ')':
-		addToken(models.RightParen, "")
-		column += 1
+		s.addToken(models.RightParen, "")
 	case '{':
-		addToken(models.LeftBrace, "")
-		column += 1
+		s.addToken(models.LeftBrace, "")
 	case '}':
-		addToken(models.RightBrace, "")
-		column += 1
+		s.addToken(models.RightBrace, "")
+	case '[':
+		s.addToken(models.LEFT_SQUARE, "")
+	case ']':
+		s.addToken(models.RIGHT_SQUARE, "")
 	case ',':
-		addToken(models.COMMA, "")
-		column += 1
+		s.addToken(models.COMMA, "")
 	case '.':
-		addToken(models.DOT, "")
-		column += 1
+		s.addToken(models.DOT, "")
 	case '-':
-		addToken(models.MINUS, "")
-		column += 1
+		if s.match('-') {
+			s.addToken(models.MINUS_MINUS, "")
+		} else if s.match('=') {
+			s.addToken(models.MINUS_EQUAL, "")
+		} else {
+			s.addToken(models.MINUS, "")
+		}
 	case '+':
-		addToken(models.PLUS, "")
-		column += 1
+		if s.match('+') {
+			s.addToken(models.PLUS_PLUS, "")
+		} else if s.match('=') {
+			s.addToken(models.PLUS_EQUAL, "")
+		} else {
+			s.addToken(models.PLUS, "")
+		}
 	case ';':
-		addToken(models.SEMICOLON, "")
-		column += 1
+		s.addToken(models.SEMICOLON, "")
+	case ':':
+		s.addToken(models.COLON, "")
 	case '*':
-		addToken(models.STAR, "")
-		column += 1
+		if s.match('=') {
+			s.addToken(models.STAR_EQUAL, "")
+		} else {
+			s.addToken(models.STAR, "")
+		}
+	case '%':
+		if s.match('=') {
+			s.addToken(models.PERCENT_EQUAL, "")
+		} else {
+			s.addToken(models.PERCENT, "")
+		}
 	case '!':
-		if match('=') {
-			addToken(models.BANG_EQUAL, "")
-			column += 2
+		if s.match('=') {
+			s.addToken(models.BANG_EQUAL, "")
 		} else {
-			addToken(models.BANG, "")
-			column += 1
+			s.addToken(models.BANG, "")
 		}
 	case '=':
-		if match('=') {
-			addToken(models.EQUAL_EQUAL, "")
-			column += 2
+		if s.match('=') {
+			s.addToken(models.EQUAL_EQUAL, "")
 		} else {
-			addToken(models.EQUAL, "")
-			column += 1
+			s.addToken(models.EQUAL, "")
 		}
 	case '>':
-		if match('=') {
-			addToken(models.GREATER_EQUAL, "")
-			column += 2
+		if s.match('=') {
+			s.addToken(models.GREATER_EQUAL, "")
 		} else {
-			addToken(models.GREATER, "")
-			column += 1
+			s.addToken(models.GREATER, "")
 		}
 	case '<':
-		if match('=') {
-			addToken(models.LESS_EQUAL, "")
-			column += 2
+		if s.match('=') {
+			s.addToken(models.LESS_EQUAL, "")
 		} else {
-			addToken(models.LESS, "")
-			column += 1
+			s.addToken(models.LESS, "")
 		}
 	case '/':
-		if match('/') {
+		if s.match('/') {
 			for {
-				if peek() == '\n' || isAtEnd() {
+				if s.peek() == '\n' || s.isAtEnd() {
 					break
 				}
-				advance()
+				s.advance()
 			}
-			column = 0
+		} else if s.match('=') {
+			s.addToken(models.SLASH_EQUAL, "")
 		} else {
-			addToken(models.SLASH, "")
+			s.addToken(models.SLASH, "")
 		}
-	case ' ':
-		column += 1
-	case '\r':
-	case '\t':
-	case '\n':
-		line += 1
-		column = 1
+	case ' ', '\r', '\t', '\n':
 	case '"':
-		_string()
-		column += 1
+		s._string()
 	default:
-		if unicode.IsDigit(c) {
-			number()
+		if isDigit(c) {
+			s.number()
 		} else if unicode.IsLetter(c) || c == '_' {
-			identifier()
+			s.identifier()
 		} else {
-			if column != 1 {
-				column += 1
-			}
-			// Error
+			s.reportError(s.startLine, s.startColumn, 1, fmt.Sprintf("Unexpected character '%c'.", c))
 		}
 	}
 }
 
-// _string adds the next string to tokens.
-func _string() {
+// _string adds the next string to tokens, with
+// its escape sequences replaced in the literal.
+func (s *Scanner) _string() {
+	var value strings.Builder
 	for {
-		if peek() == '"' || isAtEnd() {
+		if s.peek() == '"' || s.isAtEnd() {
 			break
 		}
-		if peek() == '\n' {
-			line += 1
+
+		c := s.advance()
+		if c == '\\' {
+			s.escape(&value)
+		} else {
+			value.WriteRune(c)
 		}
-		advance()
 	}
 
-	if isAtEnd() {
+	if s.isAtEnd() {
+		s.reportError(s.startLine, s.startColumn, 1, "Unterminated string.")
+		return
+	}
+
+	// The closing ".
+	s.advance()
+
+	s.addToken(models.STRING, value.String())
+}
+
+// escape writes the character the escape sequence
+// after a backslash stands for to value.
+func (s *Scanner) escape(value *strings.Builder) {
+	line, column := s.line, s.columnAt(s.current-1)
+	if s.isAtEnd() {
+		return
+	}
+
+	c := s.advance()
+	switch c {
+	case 'n':
+		value.WriteRune('\n')
+	case 't':
+		value.WriteRune('\t')
+	case '"':
+		value.WriteRune('"')
+	case '\\':
+		value.WriteRune('\\')
+	case 'u':
+		s.unicodeEscape(value, line, column)
+	default:
+		s.reportError(line, column, 2, fmt.Sprintf("Unknown escape sequence '\\%c'.", c))
 	}
+}
 
-	advance()
+// unicodeEscape writes the character named by an
+// escape like \u{1F600} to value. The \u has been
+// consumed, and line and column are where it starts.
+func (s *Scanner) unicodeEscape(value *strings.Builder, line int, column int) {
+	if !s.match('{') {
+		s.reportError(line, column, 2, "Expect '{' after '\\u'.")
+		return
+	}
+
+	digitsStart := s.current
+	for isHexDigit(s.peek()) {
+		s.advance()
+	}
+	digits := s.source[digitsStart:s.current]
+	length := s.columnAt(s.current) - column
+
+	if !s.match('}') {
+		s.reportError(line, column, length, "Expect '}' after unicode escape.")
+		return
+	}
+	length++
 
-	addToken(models.STRING, source[start:current])
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if digits == "" || len(digits) > 6 || err != nil || !utf8.ValidRune(rune(code)) {
+		s.reportError(line, column, length, fmt.Sprintf("Invalid unicode escape '\\u{%s}'.", digits))
+		return
+	}
+	value.WriteRune(rune(code))
 }
 
 // addToken adds a token to tokens.
-func addToken[T any](tokenType models.TokenType, literal T) {
-	tokens = append(tokens, models.Token{Type: tokenType, Lexeme: string(source[start:current]), Literal: literal, Column: column, Line: line})
+func (s *Scanner) addToken(tokenType models.TokenType, literal interface{}) {
+	s.tokens = append(s.tokens, models.Token{
+		Type:    tokenType,
+		Lexeme:  s.source[s.start:s.current],
+		Literal: literal,
+		Offset:  s.start,
+		Column:  s.startColumn,
+		Line:    s.startLine,
+		End:     s.position(),
+	})
 }
 
 // advance consumes and returns the next rune.
-func advance() rune {
-	next := rune(source[current])
-	current += 1
+func (s *Scanner) advance() rune {
+	next, size := utf8.DecodeRuneInString(s.source[s.current:])
+	s.current += size
+	if next == '\n' {
+		s.line += 1
+		s.lineStart = s.current
+	}
 	return next
 }
 
+// position returns where current is.
+func (s *Scanner) position() models.Position {
+	return models.Position{Offset: s.current, Line: s.line, Column: s.columnAt(s.current)}
+}
+
+// columnAt returns the column of offset,
+// which must be on the current line.
+func (s *Scanner) columnAt(offset int) int {
+	return utf8.RuneCountInString(s.source[s.lineStart:offset]) + 1
+}
+
 // identifier adds the next identifier or keyword to tokens.
-func identifier() {
+func (s *Scanner) identifier() {
 	for {
-		next := peek()
+		next := s.peek()
 		if !unicode.IsLetter(next) && !unicode.IsDigit(next) && next != '_' {
 			break
 		}
 
-		advance()
+		s.advance()
 	}
 
-	text := source[start:current]
+	text := s.source[s.start:s.current]
 	_type, exists := keywords[text]
 	if !exists {
 		_type = models.IDENTIFIER
 	}
-	addToken(_type, text)
+	s.addToken(_type, text)
 }
 
 // isAtEnd reports whether current is
 // at end of source.
-func isAtEnd() bool {
-	return current >= len(source)
+func (s *Scanner) isAtEnd() bool {
+	return s.current >= len(s.source)
 }
 
 // match reports if expected is equal to the
 // current rune and consumes it if true.
-func match(expected rune) bool {
-	if isAtEnd() {
+func (s *Scanner) match(expected rune) bool {
+	if s.isAtEnd() {
 		return false
 	}
 
-	if source[current] != byte(expected) {
+	if s.source[s.current] != byte(expected) {
 		return false
 	}
 
-	current += 1
+	s.current += 1
 	return true
 }
 
-// number adds the next number to tokens.
-func number() {
-	for {
-		if !unicode.IsDigit(peek()) {
-			break
+// number adds the next number to tokens. Ints can have a
+// 0x, 0b or 0o prefix, and doubles a fraction, an
+// exponent or both. Digits can be separated with '_'.
+func (s *Scanner) number() {
+	if s.source[s.start] == '0' {
+		switch s.peek() {
+		case 'x', 'X':
+			s.prefixedInt(16)
+			return
+		case 'b', 'B':
+			s.prefixedInt(2)
+			return
+		case 'o', 'O':
+			s.prefixedInt(8)
+			return
 		}
-		advance()
 	}
 
+	s.digits()
+
 	isDouble := false
-	if peek() == '.' {
-		advance()
+	if s.peek() == '.' && isDigit(s.peekNext()) {
 		isDouble = true
+		s.advance()
+		s.digits()
+	}
 
-		for {
-			if !unicode.IsDigit(peek()) {
-				break
-			}
-			advance()
+	if next := s.peekNext(); (s.peek() == 'e' || s.peek() == 'E') &&
+		(isDigit(next) || ((next == '+' || next == '-') && isDigit(s.peekAt(2)))) {
+		isDouble = true
+		s.advance()
+		if !isDigit(s.peek()) {
+			s.advance()
 		}
+		s.digits()
+	}
+
+	lexeme := s.source[s.start:s.current]
+	if !s.checkSeparators(lexeme, 10) {
+		return
 	}
+	text := strings.ReplaceAll(lexeme, "_", "")
 
 	if isDouble {
-		val, _ := strconv.ParseFloat(source[start:current], 64)
-		addToken(models.DOUBLE, val)
+		val, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			s.reportError(s.startLine, s.startColumn, len(lexeme), fmt.Sprintf("Double literal %s is out of range.", lexeme))
+			return
+		}
+		s.addToken(models.DOUBLE, val)
 	} else {
-		addToken(models.INT, source[start:current])
+		s.parseInt(lexeme, text, 10)
+	}
+}
+
+// prefixedInt adds the int whose base prefix starts
+// at start to tokens. The '0' has been consumed.
+func (s *Scanner) prefixedInt(base int) {
+	s.advance()
+	// Consume every letter and digit, so that a digit
+	// the base doesn't allow is reported instead of
+	// starting another token.
+	for isDigit(s.peek()) || unicode.IsLetter(s.peek()) || s.peek() == '_' {
+		s.advance()
+	}
+
+	lexeme := s.source[s.start:s.current]
+	digits := lexeme[2:]
+	if digits == "" {
+		s.reportError(s.startLine, s.startColumn, len(lexeme), fmt.Sprintf("Expect digits after '%s'.", lexeme))
+		return
+	}
+	for _, c := range digits {
+		if c != '_' && !isDigitIn(c, base) {
+			s.reportError(s.startLine, s.startColumn, len(lexeme), fmt.Sprintf("Invalid digit '%c' in base %d literal %s.", c, base, lexeme))
+			return
+		}
+	}
+	if !s.checkSeparators(digits, base) {
+		return
+	}
+
+	s.parseInt(lexeme, strings.ReplaceAll(digits, "_", ""), base)
+}
+
+// parseInt adds the int written as digits in base to
+// tokens, or reports that it doesn't fit in an int.
+func (s *Scanner) parseInt(lexeme string, digits string, base int) {
+	val, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		s.reportError(s.startLine, s.startColumn, len(lexeme), fmt.Sprintf("Integer literal %s is out of range.", lexeme))
+		return
+	}
+	s.addToken(models.INT, val)
+}
+
+// digits consumes decimal digits and separators.
+func (s *Scanner) digits() {
+	for isDigit(s.peek()) || s.peek() == '_' {
+		s.advance()
+	}
+}
+
+// checkSeparators reports whether every '_' in
+// text is between two digits in base, and reports
+// an error at the number being scanned if not.
+func (s *Scanner) checkSeparators(text string, base int) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(text)-1 || !isDigitIn(rune(text[i-1]), base) || !isDigitIn(rune(text[i+1]), base) {
+			lexeme := s.source[s.start:s.current]
+			s.reportError(s.startLine, s.startColumn, len(lexeme), fmt.Sprintf("'_' must separate digits in %s.", lexeme))
+			return false
+		}
 	}
+	return true
 }
 
 // peek returns the current character, but does
 // not consume it. Returns null character if at end
 // of source.
-func peek() rune {
-	if isAtEnd() {
+func (s *Scanner) peek() rune {
+	if s.isAtEnd() {
 		return rune('\u0000')
 	}
-	return rune(source[current])
+	next, _ := utf8.DecodeRuneInString(s.source[s.current:])
+	return next
+}
+
+// peekNext returns the rune after the current one,
+// or a null character if there isn't one.
+func (s *Scanner) peekNext() rune {
+	return s.peekAt(1)
+}
+
+// peekAt returns the rune n runes after the
+// current one, or a null character if there
+// isn't one.
+func (s *Scanner) peekAt(n int) rune {
+	offset := s.current
+	for ; n > 0 && offset < len(s.source); n-- {
+		_, size := utf8.DecodeRuneInString(s.source[offset:])
+		offset += size
+	}
+	if offset >= len(s.source) {
+		return rune('\u0000')
+	}
+	next, _ := utf8.DecodeRuneInString(s.source[offset:])
+	return next
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c rune) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// isDigitIn reports whether c is a digit in base,
+// which is 2, 8, 10 or 16.
+func isDigitIn(c rune, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 8:
+		return '0' <= c && c <= '7'
+	case 10:
+		return isDigit(c)
+	}
+	return isHexDigit(c)
 }
 
 func PrintTokens(tokens []models.Token) {
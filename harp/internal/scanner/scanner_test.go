@@ -0,0 +1,268 @@
+package scanner_test
+
+import (
+	"testing"
+
+	"github.com/astraikis/harp/internal/models"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"newline", `"a\nb"`, "a\nb"},
+		{"tab", `"a\tb"`, "a\tb"},
+		{"quote", `"say \"hi\""`, `say "hi"`},
+		{"backslash", `"a\\b"`, `a\b`},
+		{"unicode", `"\u{48}\u{1F600}"`, "H\U0001F600"},
+		{"no escapes", `"plain"`, "plain"},
+		{"escaped backslash before a quote", `"\\"`, `\`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, errs := scanner.Scan(test.source)
+			if len(errs) > 0 {
+				t.Fatalf("scan: %v", errs)
+			}
+			if tokens[0].Type != models.STRING {
+				t.Fatalf("token type = %s, want STRING", models.TokenTypesNames[tokens[0].Type])
+			}
+			if tokens[0].Literal != test.want {
+				t.Errorf("literal = %q, want %q", tokens[0].Literal, test.want)
+			}
+			if tokens[0].Lexeme != test.source {
+				t.Errorf("lexeme = %q, want %q", tokens[0].Lexeme, test.source)
+			}
+		})
+	}
+}
+
+// scanErrorTests pairs sources with the exact errors
+// the scanner reports for them, in order, and the
+// width of the code each error underlines.
+var scanErrorTests = []struct {
+	name    string
+	source  string
+	want    []string
+	lengths []int
+}{
+	{
+		name:    "unknown escape",
+		source:  `print("a\qb");`,
+		want:    []string{"[Line 1:9] Error: Unknown escape sequence '\\q'."},
+		lengths: []int{2},
+	},
+	{
+		name:    "unicode escape without a brace",
+		source:  `"\u48"`,
+		want:    []string{"[Line 1:2] Error: Expect '{' after '\\u'."},
+		lengths: []int{2},
+	},
+	{
+		name:    "unclosed unicode escape",
+		source:  `"\u{48"`,
+		want:    []string{"[Line 1:2] Error: Expect '}' after unicode escape."},
+		lengths: []int{5},
+	},
+	{
+		name:    "empty unicode escape",
+		source:  `"\u{}"`,
+		want:    []string{"[Line 1:2] Error: Invalid unicode escape '\\u{}'."},
+		lengths: []int{4},
+	},
+	{
+		name:    "unicode escape past the last rune",
+		source:  `"\u{110000}"`,
+		want:    []string{"[Line 1:2] Error: Invalid unicode escape '\\u{110000}'."},
+		lengths: []int{10},
+	},
+	{
+		name:    "surrogate unicode escape",
+		source:  `"\u{D800}"`,
+		want:    []string{"[Line 1:2] Error: Invalid unicode escape '\\u{D800}'."},
+		lengths: []int{8},
+	},
+	{
+		name:    "every bad escape in a string",
+		source:  `"\a\b"`,
+		want:    []string{"[Line 1:2] Error: Unknown escape sequence '\\a'.", "[Line 1:4] Error: Unknown escape sequence '\\b'."},
+		lengths: []int{2, 2},
+	},
+	{
+		name:    "unterminated string",
+		source:  `print("abc);`,
+		want:    []string{"[Line 1:7] Error: Unterminated string."},
+		lengths: []int{1},
+	},
+	{
+		name:    "unterminated string over lines",
+		source:  "int x = 1;\nprint(\"a\nb);",
+		want:    []string{"[Line 2:7] Error: Unterminated string."},
+		lengths: []int{1},
+	},
+	{
+		name:    "string ending in a backslash",
+		source:  `"abc\`,
+		want:    []string{"[Line 1:1] Error: Unterminated string."},
+		lengths: []int{1},
+	},
+	{
+		name:    "unexpected character",
+		source:  "int x = 1;\n  x = x @ 2;",
+		want:    []string{"[Line 2:9] Error: Unexpected character '@'."},
+		lengths: []int{1},
+	},
+	{
+		name:    "unexpected character after multi-byte ones",
+		source:  `"ñé" # 1`,
+		want:    []string{"[Line 1:6] Error: Unexpected character '#'."},
+		lengths: []int{1},
+	},
+	{
+		name:    "bad escape after multi-byte characters",
+		source:  `"ñé\x"`,
+		want:    []string{"[Line 1:4] Error: Unknown escape sequence '\\x'."},
+		lengths: []int{2},
+	},
+}
+
+func TestScanErrors(t *testing.T) {
+	for _, test := range scanErrorTests {
+		t.Run(test.name, func(t *testing.T) {
+			_, errs := scanner.Scan(test.source)
+			if len(errs) != len(test.want) {
+				t.Fatalf("got %d errors, want %d\ngot:  %q\nwant: %q", len(errs), len(test.want), errs, test.want)
+			}
+			for i, err := range errs {
+				if err.Error() != test.want[i] {
+					t.Errorf("error %d = %q, want %q", i, err.Error(), test.want[i])
+				}
+				if length := err.(*scanner.ScanError).Length; length != test.lengths[i] {
+					t.Errorf("error %d length = %d, want %d", i, length, test.lengths[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"0", int64(0)},
+		{"42", int64(42)},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"1_000_000", int64(1000000)},
+		{"0xff", int64(255)},
+		{"0XDEAD_BEEF", int64(0xDEADBEEF)},
+		{"0x7fff_ffff_ffff_ffff", int64(9223372036854775807)},
+		{"0b1010", int64(10)},
+		{"0o17", int64(15)},
+		{"1.5", 1.5},
+		{"3.141_592", 3.141592},
+		{"1e3", 1000.0},
+		{"2.5E-2", 0.025},
+		{"1e+2", 100.0},
+		{"1.7976931348623157e308", 1.7976931348623157e308},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			tokens, errs := scanner.Scan(test.source)
+			if len(errs) > 0 {
+				t.Fatalf("scan: %v", errs)
+			}
+			if tokens[0].Literal != test.want {
+				t.Errorf("literal = %#v, want %#v", tokens[0].Literal, test.want)
+			}
+			if tokens[0].Lexeme != test.source {
+				t.Errorf("lexeme = %q, want %q", tokens[0].Lexeme, test.source)
+			}
+		})
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"9223372036854775808", "[Line 1:1] Error: Integer literal 9223372036854775808 is out of range."},
+		{"99_999_999_999_999_999_999", "[Line 1:1] Error: Integer literal 99_999_999_999_999_999_999 is out of range."},
+		{"0x8000_0000_0000_0000", "[Line 1:1] Error: Integer literal 0x8000_0000_0000_0000 is out of range."},
+		{"0b12", "[Line 1:1] Error: Invalid digit '2' in base 2 literal 0b12."},
+		{"0o8", "[Line 1:1] Error: Invalid digit '8' in base 8 literal 0o8."},
+		{"0xfg", "[Line 1:1] Error: Invalid digit 'g' in base 16 literal 0xfg."},
+		{"0x", "[Line 1:1] Error: Expect digits after '0x'."},
+		{"1__0", "[Line 1:1] Error: '_' must separate digits in 1__0."},
+		{"10_", "[Line 1:1] Error: '_' must separate digits in 10_."},
+		{"0x_ff", "[Line 1:1] Error: '_' must separate digits in 0x_ff."},
+		{"1e400", "[Line 1:1] Error: Double literal 1e400 is out of range."},
+		{"x = 123456789012345678901;", "[Line 1:5] Error: Integer literal 123456789012345678901 is out of range."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			_, errs := scanner.Scan(test.source)
+			if len(errs) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+			}
+			if errs[0].Error() != test.want {
+				t.Errorf("error = %q, want %q", errs[0].Error(), test.want)
+			}
+		})
+	}
+}
+
+// TestTokenSpans checks that columns count characters
+// while offsets count bytes, after multi-byte characters.
+func TestTokenSpans(t *testing.T) {
+	source := "string ñé = \"ü\";\n  ñé += \"x\";"
+	want := []models.Span{
+		{Start: models.Position{Offset: 0, Line: 1, Column: 1}, End: models.Position{Offset: 6, Line: 1, Column: 7}},
+		{Start: models.Position{Offset: 7, Line: 1, Column: 8}, End: models.Position{Offset: 11, Line: 1, Column: 10}},
+		{Start: models.Position{Offset: 12, Line: 1, Column: 11}, End: models.Position{Offset: 13, Line: 1, Column: 12}},
+		{Start: models.Position{Offset: 14, Line: 1, Column: 13}, End: models.Position{Offset: 18, Line: 1, Column: 16}},
+		{Start: models.Position{Offset: 18, Line: 1, Column: 16}, End: models.Position{Offset: 19, Line: 1, Column: 17}},
+		{Start: models.Position{Offset: 22, Line: 2, Column: 3}, End: models.Position{Offset: 26, Line: 2, Column: 5}},
+		{Start: models.Position{Offset: 27, Line: 2, Column: 6}, End: models.Position{Offset: 29, Line: 2, Column: 8}},
+		{Start: models.Position{Offset: 30, Line: 2, Column: 9}, End: models.Position{Offset: 33, Line: 2, Column: 12}},
+		{Start: models.Position{Offset: 33, Line: 2, Column: 12}, End: models.Position{Offset: 34, Line: 2, Column: 13}},
+		{Start: models.Position{Offset: 34, Line: 2, Column: 13}, End: models.Position{Offset: 34, Line: 2, Column: 13}},
+	}
+
+	tokens, errs := scanner.Scan(source)
+	if len(errs) > 0 {
+		t.Fatalf("scan: %v", errs)
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+	}
+	for i, token := range tokens {
+		if token.Span() != want[i] {
+			t.Errorf("token %d %q span = %+v, want %+v", i, token.Lexeme, token.Span(), want[i])
+		}
+	}
+	if width := tokens[1].Span().Width(); width != 2 {
+		t.Errorf("width of %q = %d, want 2", tokens[1].Lexeme, width)
+	}
+}
+
+// TestMapIsAnIdentifier checks that map is left for the
+// parser to read as a type or a name, even before '<'.
+func TestMapIsAnIdentifier(t *testing.T) {
+	for _, source := range []string{"map<string, int>", "map < 5", "map(xs)"} {
+		tokens, errs := scanner.Scan(source)
+		if len(errs) > 0 {
+			t.Fatalf("scan %q: %v", source, errs)
+		}
+		if tokens[0].Type != models.IDENTIFIER {
+			t.Errorf("%q starts with %s, want IDENTIFIER", source, models.TokenTypesNames[tokens[0].Type])
+		}
+	}
+}
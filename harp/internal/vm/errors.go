@@ -0,0 +1,86 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/models"
+)
+
+type StackFrame struct {
+	Function string
+	Call     models.Span
+}
+
+type RuntimeError struct {
+	Span    models.Span
+	Message string
+	// Stack is the call stack when the error happened,
+	// innermost call first.
+	Stack []StackFrame
+	// Omitted is how many calls were trimmed from
+	// the middle of Stack, after the first
+	// models.TraceHead.
+	Omitted int
+}
+
+func (e *RuntimeError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[Line %d:%d] Error: %s", e.Span.Start.Line, e.Span.Start.Column, e.Message))
+	for i, frame := range e.Stack {
+		if i == models.TraceHead && e.Omitted > 0 {
+			sb.WriteString("\n    " + models.OmittedCalls(e.Omitted))
+		}
+		sb.WriteString(fmt.Sprintf("\n    in %s() called at [Line %d:%d]", frame.Function, frame.Call.Start.Line, frame.Call.Start.Column))
+	}
+
+	return sb.String()
+}
+
+// Diagnostic describes the error, with a note
+// at each call on the stack that led to it.
+func (e *RuntimeError) Diagnostic() *diagnostics.Diagnostic {
+	diagnostic := &diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Line:     e.Span.Start.Line,
+		Column:   e.Span.Start.Column,
+		Length:   e.Span.Width(),
+		Message:  e.Message,
+	}
+	for i, frame := range e.Stack {
+		if i == models.TraceHead && e.Omitted > 0 {
+			diagnostic.Notes = append(diagnostic.Notes, diagnostics.Note{Message: models.OmittedCalls(e.Omitted) + "."})
+		}
+		diagnostic.Notes = append(diagnostic.Notes, diagnostics.Note{
+			Line:    frame.Call.Start.Line,
+			Column:  frame.Call.Start.Column,
+			Length:  frame.Call.Width(),
+			Message: fmt.Sprintf("in %s(), called here.", frame.Function),
+		})
+	}
+
+	return diagnostic
+}
+
+// newRuntimeError returns an error at the instruction
+// the innermost frame is running, with a stack frame
+// for every Harp function call below it.
+func (vm *VM) newRuntimeError(message string) *RuntimeError {
+	err := &RuntimeError{Message: message}
+	for depth := len(vm.frames) - 1; depth >= 0; depth-- {
+		frame := vm.frames[depth]
+		span := frame.closure.function.Chunk.SpanAt(frame.ip - 1)
+		if depth == len(vm.frames)-1 {
+			err.Span = span
+		} else {
+			err.Stack[len(err.Stack)-1].Call = span
+		}
+		if depth > 0 {
+			err.Stack = append(err.Stack, StackFrame{Function: frame.closure.function.Name})
+		}
+	}
+
+	err.Stack, err.Omitted = models.TrimTrace(err.Stack)
+	return err
+}
@@ -0,0 +1,477 @@
+// Package vm runs bytecode produced by the compiler
+// package on a stack machine.
+package vm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/astraikis/harp/internal/compiler"
+	"github.com/astraikis/harp/internal/models"
+)
+
+// VM runs compiled functions. Each VM holds its own
+// globals, which are kept between calls to Run.
+type VM struct {
+	frames  []*frame
+	stack   []interface{}
+	globals map[string]interface{}
+	// openUpvalues holds the upvalues whose variables are
+	// still on the stack, ordered by their stack slot.
+	openUpvalues []*upvalue
+	// maxDepth is how many Harp function calls can be
+	// running at once, not counting the top-level code.
+	maxDepth int
+}
+
+// frame is a call to a Harp function that hasn't
+// returned yet. Its locals start at base on the stack.
+type frame struct {
+	closure *closure
+	ip      int
+	base    int
+}
+
+// closure is a function together with the variables
+// it captured from the functions around it.
+type closure struct {
+	function *compiler.Function
+	upvalues []*upvalue
+}
+
+func (c *closure) String() string {
+	return c.function.String()
+}
+
+func (c *closure) Signature() models.Signature {
+	return c.function.Signature
+}
+
+// upvalue is a captured variable. While the variable is
+// still on the stack, the upvalue refers to its slot.
+// Once the slot is popped, the value moves into closed.
+type upvalue struct {
+	slot     int
+	closed   interface{}
+	isClosed bool
+}
+
+// NewVM returns a VM whose globals hold only the builtins.
+func NewVM() *VM {
+	globals := map[string]interface{}{}
+	for name, builtin := range models.Builtins(os.Stdout) {
+		globals[name] = builtin
+	}
+	return &VM{globals: globals, maxDepth: models.DefaultMaxDepth}
+}
+
+// SetMaxDepth sets how deeply calls to Harp functions can
+// nest before they fail with a stack overflow error.
+func (vm *VM) SetMaxDepth(depth int) {
+	vm.maxDepth = depth
+}
+
+// SetOutput makes print write to out.
+func (vm *VM) SetOutput(out io.Writer) {
+	vm.globals["print"] = models.Print{Out: out}
+}
+
+// Run runs the top-level code of a program and
+// returns the runtime error that stopped it, if any.
+func Run(function *compiler.Function) error {
+	return NewVM().Run(function)
+}
+
+// Run runs the top-level code of a program and
+// returns the runtime error that stopped it, if any.
+func (vm *VM) Run(function *compiler.Function) error {
+	script := &closure{function: function}
+	vm.push(script)
+	vm.frames = append(vm.frames, &frame{closure: script})
+
+	err := vm.run()
+	if err != nil {
+		vm.frames = nil
+		vm.stack = nil
+		vm.openUpvalues = nil
+	}
+	return err
+}
+
+func (vm *VM) run() error {
+	frame := vm.frames[len(vm.frames)-1]
+
+	for {
+		code := frame.closure.function.Chunk.Code
+		op := compiler.OpCode(code[frame.ip])
+		frame.ip++
+
+		switch op {
+		case compiler.OpConstant:
+			vm.push(vm.readConstant(frame))
+		case compiler.OpNull:
+			vm.push(nil)
+		case compiler.OpTrue:
+			vm.push(true)
+		case compiler.OpFalse:
+			vm.push(false)
+		case compiler.OpPop:
+			vm.pop()
+		case compiler.OpDup:
+			vm.push(vm.peek(0))
+		case compiler.OpDupPair:
+			vm.push(vm.peek(1))
+			vm.push(vm.peek(1))
+
+		case compiler.OpGetLocal:
+			vm.push(vm.stack[frame.base+vm.readShort(frame)])
+		case compiler.OpSetLocal:
+			vm.stack[frame.base+vm.readShort(frame)] = vm.peek(0)
+		case compiler.OpGetGlobal:
+			name := vm.readConstant(frame).(string)
+			value, ok := vm.globals[name]
+			if !ok {
+				return vm.newRuntimeError(fmt.Sprintf("Undefined variable '%s'.", name))
+			}
+			vm.push(value)
+		case compiler.OpDefineGlobal:
+			vm.globals[vm.readConstant(frame).(string)] = vm.pop()
+		case compiler.OpSetGlobal:
+			name := vm.readConstant(frame).(string)
+			if _, ok := vm.globals[name]; !ok {
+				return vm.newRuntimeError(fmt.Sprintf("Undefined variable '%s'.", name))
+			}
+			vm.globals[name] = vm.peek(0)
+		case compiler.OpGetUpvalue:
+			upvalue := frame.closure.upvalues[vm.readShort(frame)]
+			if upvalue.isClosed {
+				vm.push(upvalue.closed)
+			} else {
+				vm.push(vm.stack[upvalue.slot])
+			}
+		case compiler.OpSetUpvalue:
+			upvalue := frame.closure.upvalues[vm.readShort(frame)]
+			if upvalue.isClosed {
+				upvalue.closed = vm.peek(0)
+			} else {
+				vm.stack[upvalue.slot] = vm.peek(0)
+			}
+
+		case compiler.OpEqual:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(left == right)
+		case compiler.OpNotEqual:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(left != right)
+		case compiler.OpGreater, compiler.OpGreaterEqual, compiler.OpLess, compiler.OpLessEqual,
+			compiler.OpAdd, compiler.OpSubtract, compiler.OpMultiply, compiler.OpDivide, compiler.OpModulo:
+			right := vm.pop()
+			left := vm.pop()
+			result, err := models.Binary(operators[op], left, right)
+			if err != nil {
+				return vm.newRuntimeError(err.Error())
+			}
+			vm.push(result)
+		case compiler.OpNot:
+			vm.push(!isTruthy(vm.pop()))
+		case compiler.OpNegate:
+			value, err := models.Negate(vm.pop())
+			if err != nil {
+				return vm.newRuntimeError(err.Error())
+			}
+			vm.push(value)
+
+		case compiler.OpJump:
+			offset := vm.readShort(frame)
+			frame.ip += offset
+		case compiler.OpJumpIfFalse:
+			offset := vm.readShort(frame)
+			if !isTruthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case compiler.OpLoop:
+			offset := vm.readShort(frame)
+			frame.ip -= offset
+		case compiler.OpIterate:
+			vars := int(vm.readByte(frame))
+			iterator, err := models.NewIterator(vm.pop(), vars)
+			if err != nil {
+				return vm.newRuntimeError(err.Error())
+			}
+			vm.push(iterator)
+		case compiler.OpNext:
+			offset := vm.readShort(frame)
+			values, ok := vm.pop().(*models.Iterator).Next()
+			if !ok {
+				frame.ip += offset
+			}
+			for _, value := range values {
+				vm.push(value)
+			}
+
+		case compiler.OpCall:
+			if err := vm.call(int(vm.readByte(frame))); err != nil {
+				return err
+			}
+			frame = vm.frames[len(vm.frames)-1]
+		case compiler.OpClosure:
+			function := vm.readConstant(frame).(*compiler.Function)
+			created := &closure{function: function, upvalues: make([]*upvalue, function.UpvalueCount)}
+			for i := range created.upvalues {
+				isLocal := vm.readByte(frame) == 1
+				index := vm.readShort(frame)
+				if isLocal {
+					created.upvalues[i] = vm.captureUpvalue(frame.base + index)
+				} else {
+					created.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(created)
+		case compiler.OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case compiler.OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.stack = vm.stack[:frame.base]
+			if len(vm.frames) == 0 {
+				return nil
+			}
+
+			vm.push(result)
+			frame = vm.frames[len(vm.frames)-1]
+
+		case compiler.OpList:
+			count := vm.readShort(frame)
+			elements := make([]interface{}, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(&models.List{Elements: elements})
+		case compiler.OpMap:
+			count := vm.readShort(frame)
+			base := len(vm.stack) - 2*count
+			m := models.NewMap()
+			for i := base; i < len(vm.stack); i += 2 {
+				if err := models.CheckKey(vm.stack[i]); err != nil {
+					return vm.newRuntimeError(err.Error())
+				}
+				m.Set(vm.stack[i], vm.stack[i+1])
+			}
+			vm.stack = vm.stack[:base]
+			vm.push(m)
+		case compiler.OpGetIndex:
+			index := vm.pop()
+			value, err := models.GetIndex(vm.pop(), index)
+			if err != nil {
+				return vm.newRuntimeError(err.Error())
+			}
+			vm.push(value)
+		case compiler.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			if err := models.SetIndex(vm.pop(), index, value); err != nil {
+				return vm.newRuntimeError(err.Error())
+			}
+			vm.push(value)
+
+		case compiler.OpStruct:
+			if err := vm.newInstance(int(vm.readByte(frame))); err != nil {
+				return err
+			}
+		case compiler.OpGetField:
+			name := vm.readConstant(frame).(string)
+			instance, err := vm.checkField(vm.pop(), name)
+			if err != nil {
+				return err
+			}
+			vm.push(instance.Fields[name])
+		case compiler.OpSetField:
+			name := vm.readConstant(frame).(string)
+			value := vm.pop()
+			instance, err := vm.checkField(vm.pop(), name)
+			if err != nil {
+				return err
+			}
+			instance.Fields[name] = value
+			vm.push(value)
+
+		default:
+			return vm.newRuntimeError(fmt.Sprintf("Unknown opcode %d.", op))
+		}
+	}
+}
+
+// call calls the value below the top argCount values on
+// the stack. A Harp function gets a new frame; builtins
+// and host functions run straight away.
+func (vm *VM) call(argCount int) error {
+	callee := vm.peek(argCount)
+	arguments := vm.stack[len(vm.stack)-argCount:]
+
+	if called, ok := callee.(*closure); ok {
+		if err := called.function.Signature.CheckArguments(arguments); err != nil {
+			return vm.newRuntimeError(err.Error())
+		}
+		// The first frame runs the top-level code.
+		if len(vm.frames)-1 == vm.maxDepth {
+			return vm.newRuntimeError(models.StackOverflow(vm.maxDepth))
+		}
+
+		vm.frames = append(vm.frames, &frame{closure: called, base: len(vm.stack) - argCount - 1})
+		return nil
+	}
+
+	native, ok := callee.(models.Callable)
+	if !ok {
+		return vm.newRuntimeError("Can only call functions.")
+	}
+
+	if err := native.Signature().CheckArguments(arguments); err != nil {
+		return vm.newRuntimeError(err.Error())
+	}
+	// Copy the arguments, since the stack is
+	// reused once the call returns.
+	result, err := native.Call(append([]interface{}(nil), arguments...))
+	if err != nil {
+		return vm.newRuntimeError(err.Error())
+	}
+
+	vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+	vm.push(result)
+	return nil
+}
+
+// captureUpvalue returns the upvalue for the variable in
+// slot, reusing an open one so closures that capture the
+// same variable share it.
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= slot {
+		if vm.openUpvalues[i-1].slot == slot {
+			return vm.openUpvalues[i-1]
+		}
+		i--
+	}
+
+	created := &upvalue{slot: slot}
+	vm.openUpvalues = append(vm.openUpvalues, nil)
+	copy(vm.openUpvalues[i+1:], vm.openUpvalues[i:])
+	vm.openUpvalues[i] = created
+	return created
+}
+
+// closeUpvalues closes every open upvalue
+// for a stack slot at or above last.
+func (vm *VM) closeUpvalues(last int) {
+	for len(vm.openUpvalues) > 0 {
+		upvalue := vm.openUpvalues[len(vm.openUpvalues)-1]
+		if upvalue.slot < last {
+			break
+		}
+
+		upvalue.closed = vm.stack[upvalue.slot]
+		upvalue.isClosed = true
+		vm.openUpvalues = vm.openUpvalues[:len(vm.openUpvalues)-1]
+	}
+}
+
+// newInstance replaces the struct and the fieldCount
+// name and value pairs above it with a new instance.
+func (vm *VM) newInstance(fieldCount int) error {
+	base := len(vm.stack) - fieldCount*2 - 1
+	structType, ok := vm.stack[base].(*models.Struct)
+	if !ok {
+		return vm.newRuntimeError(fmt.Sprintf("'%s' is not a struct.", models.Format(vm.stack[base])))
+	}
+
+	instance := models.NewInstance(structType)
+	for i := base + 1; i < len(vm.stack); i += 2 {
+		name := vm.stack[i].(string)
+		if _, ok := instance.Fields[name]; !ok {
+			return vm.newRuntimeError(fmt.Sprintf("Struct '%s' has no field '%s'.", structType.Name, name))
+		}
+		instance.Fields[name] = vm.stack[i+1]
+	}
+
+	vm.stack = vm.stack[:base]
+	vm.push(instance)
+	return nil
+}
+
+// checkField checks that object is an
+// instance of a struct with the field name.
+func (vm *VM) checkField(object interface{}, name string) (*models.Instance, error) {
+	if object == nil {
+		return nil, vm.newRuntimeError(fmt.Sprintf("Cannot access field '%s' of null.", name))
+	}
+	instance, ok := object.(*models.Instance)
+	if !ok {
+		return nil, vm.newRuntimeError("Only structs have fields.")
+	}
+	if _, ok := instance.Fields[name]; !ok {
+		return nil, vm.newRuntimeError(fmt.Sprintf("Struct '%s' has no field '%s'.", instance.Struct.Name, name))
+	}
+
+	return instance, nil
+}
+
+// operators maps the arithmetic and comparison
+// ops to the operators they implement.
+var operators = map[compiler.OpCode]models.TokenType{
+	compiler.OpGreater:      models.GREATER,
+	compiler.OpGreaterEqual: models.GREATER_EQUAL,
+	compiler.OpLess:         models.LESS,
+	compiler.OpLessEqual:    models.LESS_EQUAL,
+	compiler.OpAdd:          models.PLUS,
+	compiler.OpSubtract:     models.MINUS,
+	compiler.OpMultiply:     models.STAR,
+	compiler.OpDivide:       models.SLASH,
+	compiler.OpModulo:       models.PERCENT,
+}
+
+func isTruthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+
+	if boolValue, ok := value.(bool); ok {
+		return boolValue
+	}
+
+	return true
+}
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+// peek returns the value distance places below the top of the stack.
+func (vm *VM) peek(distance int) interface{} {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (vm *VM) readByte(frame *frame) byte {
+	b := frame.closure.function.Chunk.Code[frame.ip]
+	frame.ip++
+	return b
+}
+
+func (vm *VM) readShort(frame *frame) int {
+	high := vm.readByte(frame)
+	low := vm.readByte(frame)
+	return int(high)<<8 | int(low)
+}
+
+func (vm *VM) readConstant(frame *frame) interface{} {
+	return frame.closure.function.Chunk.Constants[vm.readShort(frame)]
+}
@@ -0,0 +1,171 @@
+package vm_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/compiler"
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/interpreter"
+	"github.com/astraikis/harp/internal/models"
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/resolver"
+	"github.com/astraikis/harp/internal/scanner"
+	"github.com/astraikis/harp/internal/vm"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden .out files")
+
+// goldenScripts are the example scripts at the top of the
+// repository, checked alongside the ones in testdata.
+var goldenScripts = []string{"../../../fib.harp", "../../../main.harp"}
+
+// TestBackendsMatch runs each script on the tree-walking
+// interpreter and on the VM, and checks both print what
+// the script's golden file in testdata holds.
+func TestBackendsMatch(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/*.harp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts = append(scripts, goldenScripts...)
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".harp")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmts := prepare(t, string(source))
+
+			interpreted, err := interpret(t, stmts)
+			if err != nil {
+				t.Fatalf("interpreter: %v", err)
+			}
+			compiled, err := runVM(t, stmts)
+			if err != nil {
+				t.Fatalf("vm: %v", err)
+			}
+			if interpreted != compiled {
+				t.Fatalf("backends differ\ninterpreter:\n%s\nvm:\n%s", interpreted, compiled)
+			}
+
+			checkGolden(t, filepath.Join("testdata", name+".out"), interpreted)
+		})
+	}
+}
+
+// TestBackendErrorsMatch runs each script in testdata/errors,
+// which must stop with a runtime error, on the interpreter
+// and on the VM. Both must print the same output and then
+// the same error, which the script's golden file holds.
+func TestBackendErrorsMatch(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/errors/*.harp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".harp")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmts := prepare(t, string(source))
+
+			interpreted, err := interpret(t, stmts)
+			if err == nil {
+				t.Fatal("interpreter: want a runtime error")
+			}
+			interpreted += err.Error() + "\n"
+			compiled, err := runVM(t, stmts)
+			if err == nil {
+				t.Fatal("vm: want a runtime error")
+			}
+			compiled += err.Error() + "\n"
+			if interpreted != compiled {
+				t.Fatalf("backends differ\ninterpreter:\n%s\nvm:\n%s", interpreted, compiled)
+			}
+
+			checkGolden(t, filepath.Join("testdata", "errors", name+".out"), interpreted)
+		})
+	}
+}
+
+// checkGolden checks got is what the golden file holds,
+// or rewrites the file with got if -update is set.
+func checkGolden(t *testing.T, golden string, got string) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+// prepare scans, parses, checks and resolves source,
+// failing the test on anything but warnings.
+func prepare(t *testing.T, source string) []models.Stmt {
+	t.Helper()
+
+	tokens, errs := scanner.Scan(source)
+	failOnErrors(t, errs)
+	stmts, errs := parser.Parse(tokens)
+	failOnErrors(t, errs)
+	failOnErrors(t, checker.Check(stmts))
+	failOnErrors(t, resolver.Resolve(stmts))
+	return stmts
+}
+
+func failOnErrors(t *testing.T, errs []error) {
+	t.Helper()
+	for _, err := range errs {
+		if !diagnostics.IsWarning(err) {
+			t.Fatal(errors.Join(errs...))
+		}
+	}
+}
+
+// interpret runs stmts on the interpreter and returns
+// what they print and the runtime error, if any.
+func interpret(t *testing.T, stmts []models.Stmt) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	runner := interpreter.NewInterpreter()
+	runner.SetOutput(&out)
+	err := runner.Interpret(stmts)
+	return out.String(), err
+}
+
+// runVM compiles stmts and runs them on the VM, returning
+// what they print and the runtime error, if any.
+func runVM(t *testing.T, stmts []models.Stmt) (string, error) {
+	t.Helper()
+
+	function, errs := compiler.Compile(stmts)
+	failOnErrors(t, errs)
+
+	var out bytes.Buffer
+	machine := vm.NewVM()
+	machine.SetOutput(&out)
+	err := machine.Run(function)
+	return out.String(), err
+}
@@ -0,0 +1,261 @@
+package harp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/models"
+)
+
+// toHarp converts a Go value to a Harp
+// value and returns it with its type.
+func toHarp(value interface{}) (interface{}, *checker.Type, error) {
+	if value == nil {
+		return nil, nil, fmt.Errorf("cannot convert nil")
+	}
+
+	t, err := harpType(reflect.TypeOf(value))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	harpValue, err := convertToHarp(reflect.ValueOf(value))
+	if err != nil {
+		return nil, nil, err
+	}
+	return harpValue, t, nil
+}
+
+// harpType returns the Harp type Go values
+// of type t are converted to.
+func harpType(t reflect.Type) (*checker.Type, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &checker.Type{Kind: checker.Int}, nil
+	case reflect.Float32, reflect.Float64:
+		return &checker.Type{Kind: checker.Double}, nil
+	case reflect.String:
+		return &checker.Type{Kind: checker.String}, nil
+	case reflect.Bool:
+		return &checker.Type{Kind: checker.Bool}, nil
+	case reflect.Slice:
+		elem, err := harpType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &checker.Type{Kind: checker.List, Elem: elem}, nil
+	case reflect.Map:
+		key, err := harpType(t.Key())
+		if err != nil {
+			return nil, err
+		}
+		if key.Kind == checker.List || key.Kind == checker.Map {
+			return nil, fmt.Errorf("Go type %s has a key type Harp maps can't have", t)
+		}
+		elem, err := harpType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &checker.Type{Kind: checker.Map, Key: key, Elem: elem}, nil
+	}
+
+	return nil, fmt.Errorf("Go type %s has no Harp equivalent", t)
+}
+
+// convertToHarp converts v, whose type harpType
+// accepted, to a Harp value. It fails if v holds an
+// unsigned int too big for a Harp int.
+func convertToHarp(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows a Harp int", v.Uint())
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Slice:
+		list := &models.List{Elements: make([]interface{}, v.Len())}
+		for i := 0; i < v.Len(); i++ {
+			element, err := convertToHarp(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list.Elements[i] = element
+		}
+		return list, nil
+	case reflect.Map:
+		type entry struct{ key, value interface{} }
+		entries := make([]entry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := convertToHarp(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			if err := models.CheckKey(key); err != nil {
+				return nil, err
+			}
+			value, err := convertToHarp(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{key, value})
+		}
+
+		// Go maps have no order, so the keys are
+		// sorted to keep the Harp map's order stable.
+		sort.Slice(entries, func(a, b int) bool { return lessKey(entries[a].key, entries[b].key) })
+
+		m := models.NewMap()
+		for _, e := range entries {
+			m.Set(e.key, e.value)
+		}
+		return m, nil
+	}
+
+	return nil, nil
+}
+
+// lessKey reports whether map key a sorts before
+// b. Both are Harp values of the same key type.
+func lessKey(a interface{}, b interface{}) bool {
+	switch a := a.(type) {
+	case int64:
+		return a < b.(int64)
+	case float64:
+		return a < b.(float64)
+	case string:
+		return a < b.(string)
+	case bool:
+		return !a && b.(bool)
+	}
+	return false
+}
+
+// fromHarp converts a Harp value to the
+// Go value a host program would expect.
+func fromHarp(value interface{}) interface{} {
+	return fromHarpValue(value, map[interface{}]interface{}{})
+}
+
+// fromHarpValue converts value for fromHarp.
+// converted maps each list, map and instance already
+// converted to its Go value, so one reached again, even
+// from inside itself, gives that same value back.
+func fromHarpValue(value interface{}, converted map[interface{}]interface{}) interface{} {
+	switch v := value.(type) {
+	case *models.List:
+		if elements, ok := converted[v]; ok {
+			return elements
+		}
+		elements := make([]interface{}, len(v.Elements))
+		converted[v] = elements
+		for i, element := range v.Elements {
+			elements[i] = fromHarpValue(element, converted)
+		}
+		return elements
+	case *models.Map:
+		if entries, ok := converted[v]; ok {
+			return entries
+		}
+		entries := map[interface{}]interface{}{}
+		converted[v] = entries
+		for _, key := range v.Keys() {
+			value, _ := v.Get(key)
+			entries[key] = fromHarpValue(value, converted)
+		}
+		return entries
+	case *models.Instance:
+		if fields, ok := converted[v]; ok {
+			return fields
+		}
+		fields := map[string]interface{}{}
+		converted[v] = fields
+		for name, field := range v.Fields {
+			fields[name] = fromHarpValue(field, converted)
+		}
+		return fields
+	case models.IntRange:
+		return Range{Start: v.Start, End: v.End, Step: v.Step}
+	}
+
+	return value
+}
+
+// convertFromHarp converts a Harp value to a Go value
+// of type t, which harpType must have accepted.
+func convertFromHarp(value interface{}, t reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Value{}, fmt.Errorf("cannot pass null as %s", t)
+	}
+
+	if t.Kind() == reflect.Slice {
+		list, ok := value.(*models.List)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot pass %v as %s", value, t)
+		}
+
+		slice := reflect.MakeSlice(t, len(list.Elements), len(list.Elements))
+		for i, element := range list.Elements {
+			converted, err := convertFromHarp(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(converted)
+		}
+		return slice, nil
+	}
+
+	if t.Kind() == reflect.Map {
+		m, ok := value.(*models.Map)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot pass %v as %s", value, t)
+		}
+
+		result := reflect.MakeMapWithSize(t, m.Len())
+		for _, key := range m.Keys() {
+			convertedKey, err := convertFromHarp(key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			element, _ := m.Get(key)
+			convertedElement, err := convertFromHarp(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			result.SetMapIndex(convertedKey, convertedElement)
+		}
+		return result, nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.CanConvert(t) {
+		return reflect.Value{}, fmt.Errorf("cannot pass %v as %s", value, t)
+	}
+
+	// Convert would wrap ints that don't fit around.
+	if n, ok := value.(int64); ok {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if reflect.Zero(t).OverflowInt(n) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", n, t)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			if n < 0 || reflect.Zero(t).OverflowUint(uint64(n)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", n, t)
+			}
+		}
+	}
+	return v.Convert(t), nil
+}
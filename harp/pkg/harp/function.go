@@ -0,0 +1,103 @@
+package harp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/models"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// goFunction adapts a Go function to models.Callable.
+type goFunction struct {
+	name      string
+	fn        reflect.Value
+	signature *checker.Type
+	// returnsError is whether fn's last result is an error.
+	returnsError bool
+}
+
+func newGoFunction(name string, fn interface{}) (*goFunction, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%T is not a function", fn)
+	}
+
+	t := v.Type()
+	if t.IsVariadic() {
+		return nil, errors.New("variadic functions aren't supported")
+	}
+
+	function := &goFunction{
+		name:      name,
+		fn:        v,
+		signature: &checker.Type{Kind: checker.Func, Return: &checker.Type{Kind: checker.Void}},
+	}
+
+	for i := 0; i < t.NumIn(); i++ {
+		param, err := harpType(t.In(i))
+		if err != nil {
+			return nil, err
+		}
+		function.signature.Params = append(function.signature.Params, param)
+	}
+
+	results := t.NumOut()
+	if results > 0 && t.Out(results-1) == errorType {
+		function.returnsError = true
+		results--
+	}
+	if results > 1 {
+		return nil, errors.New("functions can return at most one value and an error")
+	}
+	if results == 1 {
+		result, err := harpType(t.Out(0))
+		if err != nil {
+			return nil, err
+		}
+		function.signature.Return = result
+	}
+
+	return function, nil
+}
+
+func (f *goFunction) Call(arguments []interface{}) (interface{}, error) {
+	t := f.fn.Type()
+
+	in := make([]reflect.Value, len(arguments))
+	for i, argument := range arguments {
+		converted, err := convertFromHarp(argument, t.In(i))
+		if err != nil {
+			return nil, fmt.Errorf("Argument %d to '%s': %s.", i+1, f.name, err)
+		}
+		in[i] = converted
+	}
+
+	out := f.fn.Call(in)
+	if f.returnsError {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return nil, nil
+	}
+	result, err := convertToHarp(out[0])
+	if err != nil {
+		return nil, fmt.Errorf("Result of '%s': %s.", f.name, err)
+	}
+	return result, nil
+}
+
+func (f *goFunction) Signature() models.Signature {
+	return f.signature.Signature()
+}
+
+func (f *goFunction) String() string {
+	return "<func " + f.name + ">"
+}
@@ -0,0 +1,146 @@
+// Package harp runs Harp programs from Go.
+//
+// A VM keeps its globals between calls to Run, so a host
+// program can define values and functions with Set and
+// RegisterFunc, run scripts that use them, and read the
+// results back with Get:
+//
+//	vm := harp.NewVM()
+//	vm.Set("limit", 10)
+//	vm.RegisterFunc("twice", func(n int) int { return n * 2 })
+//	err := vm.Run(`int result = twice(limit);`)
+//	result, _ := vm.Get("result") // 20
+package harp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/astraikis/harp/internal/checker"
+	"github.com/astraikis/harp/internal/diagnostics"
+	"github.com/astraikis/harp/internal/interpreter"
+	"github.com/astraikis/harp/internal/parser"
+	"github.com/astraikis/harp/internal/resolver"
+	"github.com/astraikis/harp/internal/scanner"
+)
+
+// VM runs Harp source. A VM isn't safe for concurrent
+// use, but separate VMs can run at the same time.
+type VM struct {
+	checker     *checker.Checker
+	interpreter *interpreter.Interpreter
+}
+
+// NewVM returns a VM whose globals hold only the builtins.
+func NewVM() *VM {
+	return &VM{
+		checker:     checker.NewChecker(),
+		interpreter: interpreter.NewInterpreter(),
+	}
+}
+
+// Run scans, parses, checks and runs source. If source has
+// syntax, type or scope errors, none of it runs and the
+// errors are returned joined together. Otherwise the runtime error
+// that stopped it is returned, if any.
+func (vm *VM) Run(source string) error {
+	tokens, scanErrors := scanner.Scan(source)
+	if scanErrors != nil {
+		return errors.Join(scanErrors...)
+	}
+
+	stmts, parseErrors := parser.Parse(tokens)
+	if parseErrors != nil {
+		return errors.Join(parseErrors...)
+	}
+
+	checkErrors := vm.checker.Check(stmts)
+	if checkErrors != nil {
+		return errors.Join(checkErrors...)
+	}
+
+	// Warnings are dropped, since they don't stop source running.
+	var resolveErrors []error
+	for _, resolveError := range resolver.Resolve(stmts) {
+		if !diagnostics.IsWarning(resolveError) {
+			resolveErrors = append(resolveErrors, resolveError)
+		}
+	}
+	if resolveErrors != nil {
+		return errors.Join(resolveErrors...)
+	}
+
+	return vm.interpreter.Interpret(stmts)
+}
+
+// Set defines the global name with value. Value must be an
+// int, float, string or bool, or a slice or map of them,
+// nested to any depth. Map keys must be ints, floats,
+// strings or bools, and can't be NaN. The Harp map keeps
+// its entries in key order, since Go maps have none.
+func (vm *VM) Set(name string, value interface{}) error {
+	harpValue, harpType, err := toHarp(value)
+	if err != nil {
+		return fmt.Errorf("harp: cannot set %s: %w", name, err)
+	}
+
+	vm.checker.DefineGlobal(name, harpType)
+	vm.interpreter.DefineGlobal(name, harpValue)
+	return nil
+}
+
+// Get returns the value of the global name converted to
+// a Go value, and reports whether name is defined. Ints
+// come back as int64, doubles as float64, lists as
+// []interface{}, ranges as a Range and structs as
+// map[string]interface{}. A value reached twice, even
+// from inside itself, comes back as the same Go slice
+// or map both times.
+func (vm *VM) Get(name string) (interface{}, bool) {
+	value, ok := vm.interpreter.GetGlobal(name)
+	if !ok {
+		return nil, false
+	}
+
+	return fromHarp(value), true
+}
+
+// Range is a Harp range as Get returns it: the ints from
+// Start up to but not including End, counting by Step.
+// Like the range itself, it doesn't hold its ints.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+// RegisterFunc defines the global function name, which
+// calls fn. Fn's parameters and results must be types Set
+// accepts. It can return at most one value, optionally
+// followed by an error, which stops the program as a
+// runtime error.
+func (vm *VM) RegisterFunc(name string, fn interface{}) error {
+	function, err := newGoFunction(name, fn)
+	if err != nil {
+		return fmt.Errorf("harp: cannot register %s: %w", name, err)
+	}
+
+	vm.checker.DefineGlobal(name, function.signature)
+	vm.interpreter.DefineGlobal(name, function)
+	return nil
+}
+
+// SetStdout makes print write to out instead of os.Stdout.
+func (vm *VM) SetStdout(out io.Writer) {
+	vm.interpreter.SetOutput(out)
+}
+
+// SetMaxDepth sets how deeply calls to Harp functions can
+// nest before Run fails with a stack overflow error. The
+// default is 10000. Run also fails with a stack overflow
+// error if calls whose bodies nest deeply would run the
+// goroutine out of stack first.
+func (vm *VM) SetMaxDepth(depth int) {
+	vm.interpreter.SetMaxDepth(depth)
+}
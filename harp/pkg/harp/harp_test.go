@@ -0,0 +1,206 @@
+package harp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// concurrentScript exercises globals, host functions, closures,
+// recursion and print, so that VMs running it at once would
+// trip the race detector if they shared any state.
+const concurrentScript = `
+func fib(int n) int {
+	if (n < 2) {
+		return n;
+	}
+	return fib(n - 1) + fib(n - 2);
+}
+
+func counter() func() int {
+	int count = 0;
+	return func() int {
+		count += 1;
+		return count;
+	};
+}
+
+func() int next = counter();
+for (int i = 0; i < seed; i++) {
+	next();
+}
+
+list<int> seen = [];
+append(seen, scale(fib(seed)));
+int result = next() + seen[0];
+print(result);
+`
+
+func TestVMsRunConcurrently(t *testing.T) {
+	const vms = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, vms)
+	for n := 0; n < vms; n++ {
+		wg.Add(1)
+		go func(seed int) {
+			defer wg.Done()
+			errs <- runSeeded(seed)
+		}(n + 10)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// runSeeded runs concurrentScript on its own VM
+// and checks the result against the same sum in Go.
+func runSeeded(seed int) error {
+	vm := NewVM()
+	var out bytes.Buffer
+	vm.SetStdout(&out)
+	vm.SetMaxDepth(1000 + seed)
+	if err := vm.Set("seed", seed); err != nil {
+		return err
+	}
+	if err := vm.RegisterFunc("scale", func(n int) int { return n * seed }); err != nil {
+		return err
+	}
+
+	if err := vm.Run(concurrentScript); err != nil {
+		return fmt.Errorf("seed %d: %w", seed, err)
+	}
+
+	want := int64(seed + 1 + fib(seed)*seed)
+	result, ok := vm.Get("result")
+	if !ok || result != want {
+		return fmt.Errorf("seed %d: result = %v, want %d", seed, result, want)
+	}
+	if got := out.String(); got != fmt.Sprintf("%d\n", want) {
+		return fmt.Errorf("seed %d: printed %q, want %d", seed, got, want)
+	}
+	return nil
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func TestIntConversionsDontWrap(t *testing.T) {
+	vm := NewVM()
+	if err := vm.RegisterFunc("narrow", func(n int32) int32 { return n }); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.RegisterFunc("huge", func() uint { return math.MaxUint64 }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.Run(`int n = narrow(2147483647);`); err != nil {
+		t.Errorf("narrow(MaxInt32): %v", err)
+	}
+	if err := vm.Run(`narrow(5000000000);`); err == nil || !strings.Contains(err.Error(), "5000000000 overflows int32") {
+		t.Errorf("narrow(5000000000) error = %v, want overflow", err)
+	}
+	if err := vm.Run(`huge();`); err == nil || !strings.Contains(err.Error(), "overflows a Harp int") {
+		t.Errorf("huge() error = %v, want overflow", err)
+	}
+	if err := vm.Set("big", uint(math.MaxUint64)); err == nil {
+		t.Error("Set with a uint above MaxInt64 succeeded")
+	}
+}
+
+func TestNaNMapKeysAreRejected(t *testing.T) {
+	vm := NewVM()
+	if err := vm.Run(`map<double, int> m = {}; m[0.0 / 0.0] = 1;`); err == nil || !strings.Contains(err.Error(), "Map key cannot be NaN.") {
+		t.Errorf("setting a NaN key: error = %v, want it rejected", err)
+	}
+	if err := vm.Set("m", map[float64]int{math.NaN(): 1}); err == nil {
+		t.Error("Set with a NaN map key succeeded")
+	}
+}
+
+func TestDeepRecursionFailsWithoutCrashing(t *testing.T) {
+	vm := NewVM()
+	vm.SetMaxDepth(math.MaxInt32)
+
+	// The body nests blocks, loops and a deep expression
+	// around the recursive call, so each call takes far
+	// more Go stack than a plain one.
+	err := vm.Run(`
+func f(int n) int {
+	if (n == 0) {
+		return 0;
+	}
+	int r = 0;
+	{
+		for (int i = 0; i < 1; i++) {
+			while (true) {
+				r = 1 + (2 * (3 + (4 * (5 + (6 * (7 + (8 * (0 + f(n - 1))))))))) - 1;
+				break;
+			}
+		}
+	}
+	return r;
+}
+f(10000000);
+`)
+	if err == nil || !strings.Contains(err.Error(), "Stack overflow") {
+		t.Errorf("error = %v, want a stack overflow", err)
+	}
+}
+
+func TestGetValuesThatHoldThemselves(t *testing.T) {
+	vm := NewVM()
+	err := vm.Run(`struct Node {
+    int value;
+    Node next;
+}
+Node n = Node{value: 1};
+n.next = n;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, ok := vm.Get("n")
+	if !ok {
+		t.Fatal("n is not defined")
+	}
+	node := value.(map[string]interface{})
+	next, ok := node["next"].(map[string]interface{})
+	if !ok || next["value"] != int64(1) || reflect.ValueOf(next).Pointer() != reflect.ValueOf(node).Pointer() {
+		t.Errorf("n.next = %v, want n itself", node["next"])
+	}
+}
+
+func TestGetRangesWithoutTheirInts(t *testing.T) {
+	vm := NewVM()
+	if err := vm.Run(`range all = range(0, 9223372036854775807);
+range down = range(10, 0, -2);`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want Range
+	}{
+		{"all", Range{Start: 0, End: math.MaxInt64, Step: 1}},
+		{"down", Range{Start: 10, End: 0, Step: -2}},
+	}
+	for _, test := range tests {
+		if got, ok := vm.Get(test.name); !ok || got != test.want {
+			t.Errorf("Get(%q) = %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}